@@ -0,0 +1,344 @@
+package web
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// 各个数据来源对应的 tag，例如
+//
+//	type UserReq struct {
+//	    ID   int64    `path:"id"`
+//	    Page int      `query:"page"`
+//	    Tags []string `form:"tag"`
+//	    Token string  `header:"X-Token"`
+//	}
+//
+// 没有 tag 的字段使用字段名作为 key，tag 为 - 的字段会被忽略
+const (
+	tagQuery  = "query"
+	tagForm   = "form"
+	tagPath   = "path"
+	tagHeader = "header"
+	// tagTimeFormat 指定 time.Time 的格式，默认是 time.RFC3339
+	tagTimeFormat = "time_format"
+)
+
+// 常用的 Content-Type
+const (
+	MIMEJSON          = "application/json"
+	MIMEXML           = "application/xml"
+	MIMETextXML       = "text/xml"
+	MIMEForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartForm = "multipart/form-data"
+	MIMEHTML          = "text/html"
+	MIMEPlain         = "text/plain"
+	MIMEProtobuf      = "application/x-protobuf"
+	MIMEMsgpack       = "application/msgpack"
+	MIMEXMsgpack      = "application/x-msgpack"
+)
+
+// defaultMultipartMemory 解析 multipart 表单时最多放在内存里的字节数，超出的部分会放到临时文件中
+const defaultMultipartMemory = 32 << 20
+
+var (
+	// ErrUnsupportedMediaType 不支持的 Content-Type
+	ErrUnsupportedMediaType = NewHTTPError(http.StatusUnsupportedMediaType, "")
+
+	errBindTarget = errors.New("web: 只能绑定到非 nil 的结构体指针")
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// BindError 绑定某个字段失败
+type BindError struct {
+	// Source 数据来源，例如 query
+	Source string
+	// Field 对应的 key，解析整个请求体失败的时候为空
+	Field string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("web: 解析 %s 请求体失败: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("web: 绑定 %s 参数 %s 失败: %v", e.Source, e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Bind 根据 Content-Type 选择解析请求体的方式，表单以外的类型都交给对应的 Codec
+// 没有 Content-Type 的时候，例如 GET 请求，会从查询参数中绑定
+// 所有的 Bind 方法在绑定成功之后，都会使用 Validator 校验 val
+func (c *Context) Bind(val any) error {
+	return c.bindAndValidate(val, c.bindBody)
+}
+
+func (c *Context) bindBody(val any) error {
+	ct := c.Req.Header.Get("Content-Type")
+	if ct == "" {
+		return c.bindQuery(val)
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ErrUnsupportedMediaType.Wrap(err)
+	}
+	switch mediaType {
+	case MIMEJSON:
+		return c.bindJSON(val)
+	case MIMEForm, MIMEMultipartForm:
+		return c.bindForm(val)
+	default:
+		// 其它类型交给注册的 Codec，找不到的时候返回 ErrUnsupportedMediaType
+		return c.bindCodec(ct, val)
+	}
+}
+
+// bindAndValidate 绑定成功之后校验
+func (c *Context) bindAndValidate(val any, bind func(val any) error) error {
+	if err := bind(val); err != nil {
+		return err
+	}
+	return c.validate(val)
+}
+
+func (c *Context) BindXML(val any) error {
+	return c.bindAndValidate(val, c.bindXML)
+}
+
+func (c *Context) bindXML(val any) error {
+	return c.bindCodec(MIMEXML, val)
+}
+
+// BindProtobuf 不管 Content-Type 是什么，都按照 Protobuf 解析请求体
+func (c *Context) BindProtobuf(msg proto.Message) error {
+	return c.BindWith(MIMEProtobuf, msg)
+}
+
+// BindMsgpack 不管 Content-Type 是什么，都按照 MessagePack 解析请求体
+func (c *Context) BindMsgpack(val any) error {
+	return c.BindWith(MIMEMsgpack, val)
+}
+
+// BindQuery 根据 query tag 绑定查询参数
+func (c *Context) BindQuery(val any) error {
+	return c.bindAndValidate(val, c.bindQuery)
+}
+
+func (c *Context) bindQuery(val any) error {
+	query := c.queryValues()
+	return bindValues(val, tagQuery, func(key string) []string {
+		return query[key]
+	})
+}
+
+// BindForm 根据 form tag 绑定表单，包括 multipart 表单。
+// 和 http.Request.Form 一样，查询参数也在其中
+func (c *Context) BindForm(val any) error {
+	return c.bindAndValidate(val, c.bindForm)
+}
+
+func (c *Context) bindForm(val any) error {
+	if err := c.parseForm(); err != nil {
+		return err
+	}
+	return bindValues(val, tagForm, func(key string) []string {
+		return c.cacheFormValues[key]
+	})
+}
+
+// BindPath 根据 path tag 绑定路径参数
+func (c *Context) BindPath(val any) error {
+	return c.bindAndValidate(val, c.bindPath)
+}
+
+func (c *Context) bindPath(val any) error {
+	return bindValues(val, tagPath, func(key string) []string {
+		v, ok := c.PathParams[key]
+		if !ok {
+			return nil
+		}
+		return []string{v}
+	})
+}
+
+// BindHeader 根据 header tag 绑定请求头，key 不区分大小写
+func (c *Context) BindHeader(val any) error {
+	return c.bindAndValidate(val, c.bindHeader)
+}
+
+func (c *Context) bindHeader(val any) error {
+	return bindValues(val, tagHeader, func(key string) []string {
+		return c.Req.Header[textproto.CanonicalMIMEHeaderKey(key)]
+	})
+}
+
+// bindValues 将 get 返回的值，按照 tag 指定的 key 设置到 val 的字段上
+func bindValues(val any, tag string, get func(key string) []string) error {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errBindTarget
+	}
+	return bindStruct(v.Elem(), tag, "", get)
+}
+
+// bindStruct prefix 是嵌套结构体的 key 前缀，
+// 例如 Addr Address `query:"addr"`，那么 Address 里面的 City 字段对应的 key 就是 addr.city
+func bindStruct(v reflect.Value, tag string, prefix string, get func(key string) []string) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		fd := typ.Field(i)
+		// 匿名字段即便类型没有导出，它里面导出的字段也是可以设置的
+		if !fd.IsExported() && !(fd.Anonymous && fd.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name, ok := fd.Tag.Lookup(tag)
+		if name == "-" {
+			continue
+		}
+		if !ok {
+			name = fd.Name
+		}
+		fv := v.Field(i)
+		if isNestedStruct(fd.Type) {
+			nestedPrefix := prefix
+			// 没有 tag 的嵌套结构体，它的字段和外层的字段平铺在一起
+			if ok {
+				nestedPrefix = prefix + name + "."
+			}
+			if err := bindNested(fv, tag, nestedPrefix, get); err != nil {
+				return err
+			}
+			continue
+		}
+		key := prefix + name
+		vals := get(key)
+		if len(vals) == 0 {
+			continue
+		}
+		if err := setValue(fv, vals, fd.Tag.Get(tagTimeFormat)); err != nil {
+			return &BindError{Source: tag, Field: key, Err: err}
+		}
+	}
+	return nil
+}
+
+// bindNested 处理嵌套结构体，如果是指针并且没有任何字段被设置，那么保持 nil
+func bindNested(fv reflect.Value, tag string, prefix string, get func(key string) []string) error {
+	if fv.Kind() != reflect.Pointer {
+		return bindStruct(fv, tag, prefix, get)
+	}
+	found := false
+	probe := func(key string) []string {
+		vals := get(key)
+		if len(vals) > 0 {
+			found = true
+		}
+		return vals
+	}
+	nv := reflect.New(fv.Type().Elem())
+	if err := bindStruct(nv.Elem(), tag, prefix, probe); err != nil {
+		return err
+	}
+	if found {
+		fv.Set(nv)
+	}
+	return nil
+}
+
+func isNestedStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && typ != timeType &&
+		!reflect.PointerTo(typ).Implements(textUnmarshalerType)
+}
+
+func setValue(fv reflect.Value, vals []string, timeFormat string) error {
+	if fv.Kind() == reflect.Pointer {
+		nv := reflect.New(fv.Type().Elem())
+		if err := setValue(nv.Elem(), vals, timeFormat); err != nil {
+			return err
+		}
+		fv.Set(nv)
+		return nil
+	}
+	if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), []string{val}, timeFormat); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setString(fv, vals[0], timeFormat)
+}
+
+func setString(fv reflect.Value, val string, timeFormat string) error {
+	if fv.Type() == timeType && timeFormat != "" {
+		t, err := time.Parse(timeFormat, val)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	// time.Time 也实现了 TextUnmarshaler，格式是 time.RFC3339
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(val))
+	}
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("web: 不支持的类型 %s", fv.Type())
+	}
+	return nil
+}
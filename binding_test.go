@@ -0,0 +1,231 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindAddress struct {
+	City   string `query:"city" form:"city"`
+	Street string `query:"street" form:"street"`
+}
+
+type bindPage struct {
+	Page int `query:"page"`
+	Size int `query:"size"`
+}
+
+// bindLevel 自定义的 TextUnmarshaler
+type bindLevel int
+
+func (l *bindLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("未知的 level")
+	}
+	return nil
+}
+
+type bindQueryReq struct {
+	bindPage
+	Name     string        `query:"name"`
+	Tags     []string      `query:"tag"`
+	IDs      []int64       `query:"id"`
+	Age      *int          `query:"age"`
+	Admin    bool          `query:"admin"`
+	Score    float64       `query:"score"`
+	Birthday time.Time     `query:"birthday" time_format:"2006-01-02"`
+	Created  time.Time     `query:"created"`
+	Timeout  time.Duration `query:"timeout"`
+	Level    bindLevel     `query:"level"`
+	Addr     bindAddress   `query:"addr"`
+	Company  *bindAddress  `query:"company"`
+	Ignored  string        `query:"-"`
+	NoTag    string
+}
+
+func TestContext_BindQuery(t *testing.T) {
+	age := 18
+	testCases := []struct {
+		name    string
+		query   string
+		wantVal bindQueryReq
+		wantErr string
+	}{
+		{
+			name: "all",
+			query: "name=Tom&tag=a&tag=b&id=1&id=2&age=18&admin=true&score=9.5" +
+				"&birthday=2000-01-02&created=2023-10-01T08:00:00Z&timeout=3s&level=high" +
+				"&addr.city=shanghai&addr.street=nanjing&page=2&size=10&Ignored=abc&NoTag=xyz",
+			wantVal: bindQueryReq{
+				bindPage: bindPage{Page: 2, Size: 10},
+				Name:     "Tom",
+				Tags:     []string{"a", "b"},
+				IDs:      []int64{1, 2},
+				Age:      &age,
+				Admin:    true,
+				Score:    9.5,
+				Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+				Created:  time.Date(2023, 10, 1, 8, 0, 0, 0, time.UTC),
+				Timeout:  3 * time.Second,
+				Level:    2,
+				Addr:     bindAddress{City: "shanghai", Street: "nanjing"},
+				NoTag:    "xyz",
+			},
+		},
+		{
+			name:  "nested pointer",
+			query: "company.city=beijing",
+			wantVal: bindQueryReq{
+				Company: &bindAddress{City: "beijing"},
+			},
+		},
+		{
+			name:    "invalid int",
+			query:   "id=1&id=abc",
+			wantErr: "web: 绑定 query 参数 id 失败: strconv.ParseInt: parsing \"abc\": invalid syntax",
+		},
+		{
+			name:    "invalid text",
+			query:   "level=middle",
+			wantErr: "web: 绑定 query 参数 level 失败: 未知的 level",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)}
+			var req bindQueryReq
+			err := ctx.BindQuery(&req)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				var be *BindError
+				assert.True(t, errors.As(err, &be))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVal, req)
+		})
+	}
+}
+
+func TestContext_BindPathAndHeader(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/user/123", nil)
+	req.Header.Set("X-Token", "abc")
+	req.Header.Add("Accept-Language", "zh")
+	req.Header.Add("Accept-Language", "en")
+	ctx := &Context{Req: req, PathParams: map[string]string{"id": "123"}}
+
+	var val struct {
+		ID        int64    `path:"id"`
+		Token     string   `header:"x-token"`
+		Languages []string `header:"Accept-Language"`
+	}
+	require.NoError(t, ctx.BindPath(&val))
+	require.NoError(t, ctx.BindHeader(&val))
+	assert.Equal(t, int64(123), val.ID)
+	assert.Equal(t, "abc", val.Token)
+	assert.Equal(t, []string{"zh", "en"}, val.Languages)
+
+	assert.Equal(t, errBindTarget, ctx.BindPath(val))
+}
+
+type bindBodyReq struct {
+	Name string   `json:"name" xml:"name" form:"name"`
+	Tags []string `json:"tags" xml:"tag" form:"tag"`
+}
+
+func TestContext_Bind(t *testing.T) {
+	multipartBody := &bytes.Buffer{}
+	mw := multipart.NewWriter(multipartBody)
+	require.NoError(t, mw.WriteField("name", "Tom"))
+	require.NoError(t, mw.WriteField("tag", "a"))
+	require.NoError(t, mw.WriteField("tag", "b"))
+	require.NoError(t, mw.Close())
+
+	testCases := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantVal     bindBodyReq
+		wantErr     error
+	}{
+		{
+			name:        "json",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"Tom","tags":["a","b"]}`,
+			wantVal:     bindBodyReq{Name: "Tom", Tags: []string{"a", "b"}},
+		},
+		{
+			name:        "xml",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/xml",
+			body:        `<req><name>Tom</name><tag>a</tag><tag>b</tag></req>`,
+			wantVal:     bindBodyReq{Name: "Tom", Tags: []string{"a", "b"}},
+		},
+		{
+			name:        "form",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/x-www-form-urlencoded",
+			body:        url.Values{"name": {"Tom"}, "tag": {"a", "b"}}.Encode(),
+			wantVal:     bindBodyReq{Name: "Tom", Tags: []string{"a", "b"}},
+		},
+		{
+			name:        "multipart",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: mw.FormDataContentType(),
+			body:        multipartBody.String(),
+			wantVal:     bindBodyReq{Name: "Tom", Tags: []string{"a", "b"}},
+		},
+		{
+			name:    "query",
+			method:  http.MethodGet,
+			target:  "/?Name=Tom",
+			wantVal: bindBodyReq{Name: "Tom"},
+		},
+		{
+			name:        "unsupported",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "text/csv",
+			body:        "name,Tom",
+			wantErr:     ErrUnsupportedMediaType,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			ctx := &Context{Req: req}
+			var val bindBodyReq
+			err := ctx.Bind(&val)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
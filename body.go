@@ -0,0 +1,77 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// ErrRequestEntityTooLarge 请求体超过了 ServerWithMaxBodySize 或者 MaxBodySize 设置的上限
+var ErrRequestEntityTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge, "")
+
+// ServerWithMaxBodySize 设置所有请求的请求体大小上限，超过的时候读取请求体会失败，
+// 交给 DefaultErrorHandler 处理的时候响应 413
+// 单个路由可以使用 MaxBodySize 覆盖，n <= 0 表示不限制
+func ServerWithMaxBodySize(n int64) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.maxBodySize = n
+	}
+}
+
+// MaxBodySize 给单个路由设置请求体大小上限，会覆盖 ServerWithMaxBodySize，
+// 例如 server.POST("/upload", web.MaxBodySize(100<<20, uploader.Handle()))
+// 如果在此之前 middleware 已经通过 Body 缓存了请求体，并且超过了上限，那么直接响应 413
+func MaxBodySize(n int64, handler HandleFunc) HandleFunc {
+	return func(ctx *Context) {
+		if ctx.bodyCached {
+			if int64(len(ctx.body)) > n {
+				ctx.RespString(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+				return
+			}
+		} else {
+			ctx.limitBody(n)
+		}
+		handler(ctx)
+	}
+}
+
+// limitBody 限制的是原始的请求体，所以后设置的上限会覆盖前面的，而不是取最小值
+func (c *Context) limitBody(n int64) {
+	if c.rawBody == nil {
+		// 没有经过 HTTPServer 创建的 Context
+		c.rawBody = c.Req.Body
+	}
+	if c.rawBody == nil || n <= 0 {
+		c.Req.Body = c.rawBody
+		return
+	}
+	c.Req.Body = http.MaxBytesReader(c.Resp, c.rawBody, n)
+}
+
+// Body 读取并且缓存整个请求体，之后 Bind 系列方法、表单解析以及再次调用 Body 都会使用缓存的数据，
+// 所以签名校验、审计日志之类的 middleware 可以和业务代码读取同一份请求体
+// 不调用 Body 的时候不会缓存，请求体依旧只能读取一次
+func (c *Context) Body() ([]byte, error) {
+	if c.bodyCached {
+		c.rewindBody()
+		return c.body, nil
+	}
+	if c.Req.Body != nil {
+		data, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			return nil, err
+		}
+		_ = c.Req.Body.Close()
+		c.body = data
+	}
+	c.bodyCached = true
+	c.rewindBody()
+	return c.body, nil
+}
+
+// rewindBody 如果请求体已经被 Body 缓存了，那么重置 Req.Body，让下一个读取的人从头开始读
+func (c *Context) rewindBody() {
+	if c.bodyCached {
+		c.Req.Body = io.NopCloser(bytes.NewReader(c.body))
+	}
+}
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	type user struct {
+		Name string `json:"name" form:"name"`
+	}
+	bind := HandleE(func(ctx *Context) error {
+		var u user
+		if err := ctx.Bind(&u); err != nil {
+			return err
+		}
+		ctx.RespString(http.StatusOK, u.Name)
+		return nil
+	})
+	s := NewHTTPServer(ServerWithMaxBodySize(16))
+	s.POST("/user", bind)
+	s.POST("/large", MaxBodySize(1024, bind))
+	s.POST("/small", MaxBodySize(4, bind))
+
+	longName := `{"name":"` + strings.Repeat("a", 32) + `"}`
+	testCases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:        "global ok",
+			path:        "/user",
+			contentType: MIMEJSON,
+			body:        `{"name":"Tom"}`,
+			wantCode:    http.StatusOK,
+			wantBody:    "Tom",
+		},
+		{
+			name:        "global too large",
+			path:        "/user",
+			contentType: MIMEJSON,
+			body:        longName,
+			wantCode:    http.StatusRequestEntityTooLarge,
+			wantBody:    "Request Entity Too Large",
+		},
+		{
+			name:        "form too large",
+			path:        "/user",
+			contentType: MIMEForm,
+			body:        url.Values{"name": {strings.Repeat("a", 32)}}.Encode(),
+			wantCode:    http.StatusRequestEntityTooLarge,
+			wantBody:    "Request Entity Too Large",
+		},
+		{
+			name:        "route larger than global",
+			path:        "/large",
+			contentType: MIMEJSON,
+			body:        longName,
+			wantCode:    http.StatusOK,
+			wantBody:    strings.Repeat("a", 32),
+		},
+		{
+			name:        "route smaller than global",
+			path:        "/small",
+			contentType: MIMEJSON,
+			body:        `{"name":"Tom"}`,
+			wantCode:    http.StatusRequestEntityTooLarge,
+			wantBody:    "Request Entity Too Large",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestContext_Body(t *testing.T) {
+	secret := []byte("secret")
+	var audit string
+	// 模拟签名校验和审计日志 middleware，它们和业务代码读取的是同一份请求体
+	verify := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			body, err := ctx.Body()
+			if err != nil {
+				ctx.RespStatusCode = http.StatusRequestEntityTooLarge
+				return
+			}
+			mac := hmac.New(sha256.New, secret)
+			mac.Write(body)
+			if hex.EncodeToString(mac.Sum(nil)) != ctx.Req.Header.Get("X-Signature") {
+				ctx.RespStatusCode = http.StatusUnauthorized
+				return
+			}
+			next(ctx)
+			body, _ = ctx.Body()
+			audit = string(body)
+		}
+	}
+	s := NewHTTPServer(ServerWithMiddleware(verify))
+	s.POST("/user", HandleE(func(ctx *Context) error {
+		var u struct {
+			Name string `json:"name"`
+		}
+		if err := ctx.BindJSON(&u); err != nil {
+			return err
+		}
+		ctx.RespString(http.StatusOK, u.Name)
+		return nil
+	}))
+	s.POST("/small", MaxBodySize(4, func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}))
+
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	body := `{"name":"Tom"}`
+	testCases := []struct {
+		name      string
+		path      string
+		signature string
+		wantCode  int
+		wantBody  string
+		wantAudit string
+	}{
+		{
+			name:      "ok",
+			path:      "/user",
+			signature: sign(body),
+			wantCode:  http.StatusOK,
+			wantBody:  "Tom",
+			wantAudit: body,
+		},
+		{
+			name:      "bad signature",
+			path:      "/user",
+			signature: sign("hacked"),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			// 请求体已经被缓存了，路由的上限直接检查缓存的数据
+			name:      "cached body too large",
+			path:      "/small",
+			signature: sign(body),
+			wantCode:  http.StatusRequestEntityTooLarge,
+			wantBody:  "Request Entity Too Large",
+			wantAudit: body,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			audit = ""
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(body))
+			req.Header.Set("X-Signature", tc.signature)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantAudit, audit)
+		})
+	}
+}
+
+func TestContext_BodyWithoutServer(t *testing.T) {
+	ctx := &Context{Req: httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name=Tom"))}
+	ctx.Req.Header.Set("Content-Type", MIMEForm)
+	body, err := ctx.Body()
+	require.NoError(t, err)
+	assert.Equal(t, "name=Tom", string(body))
+	name, err := ctx.FormValue("name").String()
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", name)
+}
@@ -0,0 +1,204 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Codec 负责某一种 Content-Type 的编解码
+// 绑定请求体以及返回响应的时候，会根据 Content-Type 找到对应的 Codec
+type Codec interface {
+	// Decode 将请求体解析到 val 上
+	Decode(r io.Reader, val any) error
+	// Encode 将 val 编码为响应体
+	Encode(val any) ([]byte, error)
+}
+
+var _ Codec = JSONCodec{}
+var _ Codec = XMLCodec{}
+var _ Codec = ProtobufCodec{}
+var _ Codec = MsgpackCodec{}
+
+var errNotProtoMessage = errors.New("web: Protobuf 只支持 proto.Message")
+
+// JSONCodec 零值的行为和 encoding/json 默认的行为一致，
+// 唯一的区别是默认不允许请求中出现未知的字段
+type JSONCodec struct {
+	// AllowUnknownFields 允许请求中出现结构体里面没有的字段
+	AllowUnknownFields bool
+	// UseNumber 数字解析为 json.Number 而不是 float64
+	UseNumber bool
+	// DisableHTMLEscape 不转义 <、>、& 这些字符
+	DisableHTMLEscape bool
+}
+
+func (j JSONCodec) Decode(r io.Reader, val any) error {
+	decoder := json.NewDecoder(r)
+	if !j.AllowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if j.UseNumber {
+		decoder.UseNumber()
+	}
+	return decoder.Decode(val)
+}
+
+func (j JSONCodec) Encode(val any) ([]byte, error) {
+	if !j.DisableHTMLEscape {
+		return json.Marshal(val)
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(val); err != nil {
+		return nil, err
+	}
+	// Encoder 会在最后加一个换行符，和 json.Marshal 保持一致
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+type XMLCodec struct{}
+
+func (XMLCodec) Decode(r io.Reader, val any) error {
+	return xml.NewDecoder(r).Decode(val)
+}
+
+func (XMLCodec) Encode(val any) ([]byte, error) {
+	return xml.Marshal(val)
+}
+
+// ProtobufCodec val 必须实现 proto.Message
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Decode(r io.Reader, val any) error {
+	msg, ok := val.(proto.Message)
+	if !ok {
+		return errNotProtoMessage
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+func (ProtobufCodec) Encode(val any) ([]byte, error) {
+	msg, ok := val.(proto.Message)
+	if !ok {
+		return nil, errNotProtoMessage
+	}
+	return proto.Marshal(msg)
+}
+
+// MsgpackCodec 字段名使用 msgpack tag，没有的时候使用字段名
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Decode(r io.Reader, val any) error {
+	return msgpack.NewDecoder(r).Decode(val)
+}
+
+func (MsgpackCodec) Encode(val any) ([]byte, error) {
+	return msgpack.Marshal(val)
+}
+
+// codecs 按照 media type（不带参数，小写）组织的 Codec
+type codecs map[string]Codec
+
+func newCodecs() codecs {
+	return codecs{
+		MIMEJSON:        JSONCodec{},
+		MIMEXML:         XMLCodec{},
+		MIMETextXML:     XMLCodec{},
+		MIMEProtobuf:    ProtobufCodec{},
+		MIMEProblemJSON: JSONCodec{},
+		MIMEMsgpack:     MsgpackCodec{},
+		MIMEXMsgpack:    MsgpackCodec{},
+	}
+}
+
+// defaultCodecs 没有经过 HTTPServer 创建的 Context 使用
+var defaultCodecs = newCodecs()
+
+// get contentType 可以带参数，例如 application/json; charset=utf-8
+func (c codecs) get(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	codec, ok := c[mediaType]
+	return codec, ok
+}
+
+// ServerWithCodec 注册或者替换某个 Content-Type 的 Codec，
+// 例如替换为更快的 JSON 实现，或者支持新的类型
+func ServerWithCodec(mediaType string, codec Codec) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.codecs[strings.ToLower(mediaType)] = codec
+	}
+}
+
+func (c *Context) codec(contentType string) (Codec, error) {
+	cs := c.codecs
+	if cs == nil {
+		cs = defaultCodecs
+	}
+	codec, ok := cs.get(contentType)
+	if !ok {
+		return nil, ErrUnsupportedMediaType.Wrap(fmt.Errorf("web: 没有 %s 对应的 Codec", contentType))
+	}
+	return codec, nil
+}
+
+// BindWith 使用 contentType 对应的 Codec 解析请求体，不管请求的 Content-Type 是什么
+func (c *Context) BindWith(contentType string, val any) error {
+	return c.bindAndValidate(val, func(val any) error {
+		return c.bindCodec(contentType, val)
+	})
+}
+
+func (c *Context) bindCodec(contentType string, val any) error {
+	codec, err := c.codec(contentType)
+	if err != nil {
+		return err
+	}
+	c.rewindBody()
+	if c.Req.Body == nil {
+		return errBodyNil
+	}
+	if err = codec.Decode(c.Req.Body, val); err != nil {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		return &BindError{Source: mediaType, Err: err}
+	}
+	return nil
+}
+
+// RespEncoded 使用 contentType 对应的 Codec 编码 val
+func (c *Context) RespEncoded(code int, contentType string, val any) error {
+	codec, err := c.codec(contentType)
+	if err != nil {
+		return err
+	}
+	data, err := codec.Encode(val)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(contentType, ";") && isTextual(contentType) {
+		contentType += charsetUTF8
+	}
+	c.RespBytes(code, contentType, data)
+	return nil
+}
+
+// isTextual 文本类型的响应需要带上 charset
+func isTextual(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == MIMEJSON || mediaType == MIMEXML
+}
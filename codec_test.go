@@ -0,0 +1,227 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// csvCodec 只支持 []string 的简单 Codec
+type csvCodec struct{}
+
+func (csvCodec) Decode(r io.Reader, val any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	ptr, ok := val.(*[]string)
+	if !ok {
+		return errors.New("只支持 *[]string")
+	}
+	*ptr = strings.Split(string(data), ",")
+	return nil
+}
+
+func (csvCodec) Encode(val any) ([]byte, error) {
+	vals, ok := val.([]string)
+	if !ok {
+		return nil, errors.New("只支持 []string")
+	}
+	return []byte(strings.Join(vals, ",")), nil
+}
+
+func TestJSONCodec(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Age  any    `json:"age"`
+	}
+	body := `{"name":"<Tom>","age":18,"email":"tom@example.com"}`
+
+	var u user
+	err := JSONCodec{}.Decode(strings.NewReader(body), &u)
+	assert.EqualError(t, err, `json: unknown field "email"`)
+
+	err = JSONCodec{AllowUnknownFields: true, UseNumber: true}.Decode(strings.NewReader(body), &u)
+	require.NoError(t, err)
+	assert.Equal(t, user{Name: "<Tom>", Age: json.Number("18")}, u)
+
+	data, err := JSONCodec{}.Encode(u)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"\u003cTom\u003e","age":18}`, string(data))
+	data, err = JSONCodec{DisableHTMLEscape: true}.Encode(u)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"<Tom>","age":18}`, string(data))
+}
+
+func TestServerWithCodec(t *testing.T) {
+	s := NewHTTPServer(
+		ServerWithCodec(MIMEJSON, JSONCodec{AllowUnknownFields: true, DisableHTMLEscape: true}),
+		ServerWithCodec("text/CSV", csvCodec{}))
+	s.POST("/user", HandleE(func(ctx *Context) error {
+		var u struct {
+			Name string `json:"name"`
+		}
+		if err := ctx.Bind(&u); err != nil {
+			return err
+		}
+		return ctx.RespJSON(http.StatusOK, u)
+	}))
+	s.POST("/tags", HandleE(func(ctx *Context) error {
+		var tags []string
+		if err := ctx.BindWith("text/csv", &tags); err != nil {
+			return err
+		}
+		return ctx.Negotiate(http.StatusOK, Offers{
+			Types: []string{MIMEJSON, "text/csv"},
+			Data:  append(tags, "c"),
+		})
+	}))
+
+	testCases := []struct {
+		name        string
+		path        string
+		contentType string
+		accept      string
+		body        string
+		wantCode    int
+		wantType    string
+		wantBody    string
+	}{
+		{
+			name:        "json",
+			path:        "/user",
+			contentType: MIMEJSON,
+			body:        `{"name":"<Tom>","age":18}`,
+			wantCode:    http.StatusOK,
+			wantType:    "application/json; charset=utf-8",
+			wantBody:    `{"name":"<Tom>"}`,
+		},
+		{
+			name:        "csv",
+			path:        "/tags",
+			contentType: "text/csv",
+			accept:      "text/csv",
+			body:        "a,b",
+			wantCode:    http.StatusOK,
+			wantType:    "text/csv; charset=utf-8",
+			wantBody:    "a,b,c",
+		},
+		{
+			name:        "unsupported",
+			path:        "/user",
+			contentType: "application/yaml",
+			body:        "name: Tom",
+			wantCode:    http.StatusUnsupportedMediaType,
+			wantType:    "",
+			wantBody:    "Unsupported Media Type",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Accept", tc.accept)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	_, err := ProtobufCodec{}.Encode(map[string]string{})
+	assert.Equal(t, errNotProtoMessage, err)
+
+	s := NewHTTPServer()
+	s.POST("/name", HandleE(func(ctx *Context) error {
+		name := &wrapperspb.StringValue{}
+		if err := ctx.Bind(name); err != nil {
+			return err
+		}
+		name.Value += "!"
+		return ctx.Negotiate(http.StatusOK, Offers{
+			Types: []string{MIMEJSON, MIMEProtobuf},
+			Data:  name,
+		})
+	}))
+
+	data, err := proto.Marshal(wrapperspb.String("Tom"))
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/name", bytes.NewReader(data))
+	req.Header.Set("Content-Type", MIMEProtobuf)
+	req.Header.Set("Accept", MIMEProtobuf)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, MIMEProtobuf, recorder.Header().Get("Content-Type"))
+	res := &wrapperspb.StringValue{}
+	require.NoError(t, proto.Unmarshal(recorder.Body.Bytes(), res))
+	assert.Equal(t, "Tom!", res.Value)
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	type user struct {
+		Name string `msgpack:"name"`
+		Age  int    `msgpack:"age"`
+	}
+	s := NewHTTPServer()
+	s.POST("/user", HandleE(func(ctx *Context) error {
+		var u user
+		if err := ctx.Bind(&u); err != nil {
+			return err
+		}
+		u.Age++
+		return ctx.Negotiate(http.StatusOK, Offers{
+			Types: []string{MIMEJSON, MIMEMsgpack},
+			Data:  u,
+		})
+	}))
+
+	testCases := []struct {
+		name        string
+		contentType string
+		accept      string
+		wantType    string
+	}{
+		{
+			name:        "msgpack",
+			contentType: MIMEMsgpack,
+			accept:      MIMEMsgpack,
+			wantType:    MIMEMsgpack,
+		},
+		{
+			name:        "x-msgpack",
+			contentType: MIMEXMsgpack,
+			accept:      "application/msgpack;q=0.9, application/json;q=0.1",
+			wantType:    MIMEMsgpack,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := msgpack.Marshal(user{Name: "Tom", Age: 18})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/user", bytes.NewReader(data))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Accept", tc.accept)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			var res user
+			require.NoError(t, msgpack.Unmarshal(recorder.Body.Bytes(), &res))
+			assert.Equal(t, user{Name: "Tom", Age: 19}, res)
+		})
+	}
+}
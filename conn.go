@@ -0,0 +1,75 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ConnStats 连接状态的统计
+type ConnStats struct {
+	// 当前处于各个状态的连接数
+	New    int64
+	Active int64
+	Idle   int64
+	// Accepted 累计接收的连接数
+	Accepted int64
+	// Closed 累计关闭的连接数，被 Hijack 的连接不在此列
+	Closed int64
+	// Hijacked 累计被 Hijack 的连接数，例如升级成 WebSocket 的连接
+	Hijacked int64
+}
+
+// connTracker 根据 http.Server 的 ConnState 回调维护连接状态的统计
+// 回调只告诉我们新的状态，所以需要记住每个连接上一次的状态
+type connTracker struct {
+	mutex sync.Mutex
+	conns map[net.Conn]http.ConnState
+	s     ConnStats
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		conns: make(map[net.Conn]http.ConnState, 64),
+	}
+}
+
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if prev, ok := t.conns[conn]; ok {
+		*t.counter(prev)--
+	}
+	switch state {
+	case http.StateNew:
+		t.s.Accepted++
+	case http.StateClosed:
+		t.s.Closed++
+		delete(t.conns, conn)
+		return
+	case http.StateHijacked:
+		// 被 Hijack 之后 http.Server 就不再管这个连接了，不会再有回调
+		t.s.Hijacked++
+		delete(t.conns, conn)
+		return
+	}
+	t.conns[conn] = state
+	*t.counter(state)++
+}
+
+func (t *connTracker) counter(state http.ConnState) *int64 {
+	switch state {
+	case http.StateNew:
+		return &t.s.New
+	case http.StateActive:
+		return &t.s.Active
+	default:
+		return &t.s.Idle
+	}
+}
+
+func (t *connTracker) stats() ConnStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.s
+}
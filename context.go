@@ -1,19 +1,27 @@
 package web
 
 import (
-	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"net/netip"
+	"net/textproto"
 	"net/url"
+	"sync"
 )
 
+var errBodyNil = errors.New("web: body 为 nil")
+
 type Context struct {
 	Req  *http.Request
-	// Resp 原生的 ResponseWriter。当你直接使用 Resp 的时候，
+	// Resp 包装过的 ResponseWriter。当你直接使用 Resp 的时候，
 	// 那么相当于你绕开了 RespStatusCode 和 RespData。
-	// 响应数据直接被发送到前端，其它中间件将无法修改响应
+	// 响应数据直接被发送到前端，其它中间件将无法修改响应，
+	// 但是可以通过 Resp.Status() 和 Resp.Size() 知道发送了什么
 	// 其实我们也可以考虑将这个做成私有的
-	Resp http.ResponseWriter
+	Resp ResponseWriter
+	// resp 跟着 Context 一起复用，避免每个请求都分配一次
+	resp responseWriter
 	// 缓存的响应部分
 	// 这部分数据会在最后刷新
 	RespStatusCode int
@@ -29,88 +37,179 @@ type Context struct {
 
 	// 缓存的数据
 	cacheQueryValues url.Values
+	// cacheFormValues 和 http.Request.Form 一样，包含了查询参数
+	cacheFormValues url.Values
+	// cachePostFormValues 只有请求体里面的表单
+	cachePostFormValues url.Values
+	// body 调用 Body 之后缓存的请求体
+	body       []byte
+	bodyCached bool
+	// rawBody 没有被限制大小的原始请求体
+	rawBody io.ReadCloser
 
 	// 页面渲染的引擎
 	tplEngine TemplateEngine
+	// 处理 HandleFuncE 返回的 error
+	errHandler ErrorHandler
+	// Fail 查找业务码使用的注册表，由 Enveloped 设置
+	errCodes *ErrCodes
+	// enveloped typed handler 的返回值需要包装成 Envelope
+	enveloped bool
+	// 绑定参数之后的校验
+	validator *Validator
+	// 编解码请求体和响应体
+	codecs codecs
+	// 签名和加密 cookie
+	cookieKeys *cookieKeys
+	// 解析 ClientIP、Scheme、Host 的时候，只信任这些代理设置的请求头
+	trustedProxies []netip.Prefix
+	// sse 调用 SSE 之后创建的响应流，handler 返回之后关闭
+	sse *SSEStream
 
 	// 主要用于 session 存储
+	// Deprecated: 字符串 key 容易冲突，也不是并发安全的，请使用 Set 和 Get
 	UserValues map[string]any
+
+	// Set 和 Get 使用的存储
+	valuesMu sync.Mutex
+	values   *Values
+}
+
+// reset 清空 Context，以便放回池子里复用
+func (c *Context) reset() {
+	// UserValues 的 map 保留下来，避免重新分配
+	userValues := c.UserValues
+	for k := range userValues {
+		delete(userValues, k)
+	}
+	*c = Context{UserValues: userValues}
 }
 
 func (c *Context) Render(tplName string, data any) error {
+	if c.tplEngine == nil {
+		return errors.New("web: 没有设置模板引擎")
+	}
 	var err error
 	c.RespData, err = c.tplEngine.Render(c.Req.Context(), tplName, data)
 	c.RespStatusCode = http.StatusOK
 	if err != nil {
 		c.RespStatusCode = http.StatusInternalServerError
+		return err
 	}
-	return err
+	c.setContentType(MIMEHTML + charsetUTF8)
+	return nil
 }
 
 func (c *Context) BindJSON(val any) error {
-	if c.Req.Body == nil {
-		return errors.New("web: body 为 nil")
-	}
-	decoder := json.NewDecoder(c.Req.Body) 
-	decoder.DisallowUnknownFields()
-	// 将 req body 中的数据加到
-	return decoder.Decode(val)
+	return c.bindAndValidate(val, c.bindJSON)
+}
+
+// bindJSON 使用 application/json 对应的 Codec，默认不允许出现未知的字段
+func (c *Context) bindJSON(val any) error {
+	return c.bindCodec(MIMEJSON, val)
 }
 
 
+// FormValue 表单中 key 对应的第一个值，查询参数也算在内
 func (c *Context) FormValue(key string) StringValue {
-	if err := c.Req.ParseForm(); err != nil {
+	vals, err := c.FormValues(key)
+	if err != nil {
 		return StringValue{err: err}
 	}
-	return StringValue{val: c.Req.FormValue(key)}
+	return StringValue{val: vals[0]}
 }
 
-func (c *Context) QueryValue(key string) StringValue {
-	if c.cacheQueryValues == nil {
-		// query 每次都会重新解析
-		c.cacheQueryValues = c.Req.URL.Query()
+// FormValues 表单中 key 对应的所有值，例如复选框
+func (c *Context) FormValues(key string) ([]string, error) {
+	if err := c.parseForm(); err != nil {
+		return nil, err
 	}
-	vals, ok := c.cacheQueryValues[key]
-	if !ok {
-		return StringValue{err: errors.New("web: 找不到这个 key")}
+	return lookupValues(c.cacheFormValues, key)
+}
+
+// PostFormValue 只从请求体的表单中读取，不包括查询参数
+func (c *Context) PostFormValue(key string) StringValue {
+	if err := c.parseForm(); err != nil {
+		return StringValue{err: err}
+	}
+	vals, err := lookupValues(c.cachePostFormValues, key)
+	if err != nil {
+		return StringValue{err: err}
 	}
 	return StringValue{val: vals[0]}
 }
 
-func (c *Context) PathValue(key string) StringValue {
-	val, ok := c.PathParams[key]
-	if !ok {
-		return StringValue{err: errors.New("web: 找不到这个 key")}
+// parseForm 解析表单，包括 multipart 表单，只会解析一次
+func (c *Context) parseForm() error {
+	if c.cacheFormValues != nil {
+		return nil
 	}
-	return StringValue{val: val}
+	c.rewindBody()
+	// ParseMultipartForm 遇到非 multipart 的请求会吞掉 ParseForm 的错误，例如请求体太大，
+	// 所以先单独调用一次 ParseForm
+	if err := c.Req.ParseForm(); err != nil {
+		return err
+	}
+	err := c.Req.ParseMultipartForm(defaultMultipartMemory)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	c.cacheFormValues = c.Req.Form
+	c.cachePostFormValues = c.Req.PostForm
+	return nil
 }
 
-func (c *Context) setCookie(cookie *http.Cookie) {
-	http.SetCookie(c.Resp, cookie)
+func (c *Context) QueryValue(key string) StringValue {
+	vals, err := c.QueryValues(key)
+	if err != nil {
+		return StringValue{err: err}
+	}
+	return StringValue{val: vals[0]}
 }
 
-func (c *Context) RespOK(val any) error {
-	return c.RespJSON(http.StatusOK, val)
+// QueryValues 查询参数中 key 对应的所有值，例如 ?tag=a&tag=b
+func (c *Context) QueryValues(key string) ([]string, error) {
+	return lookupValues(c.queryValues(), key)
 }
 
-func (c *Context) RespJSON(code int, val any) error {
-	data, err := json.Marshal(val)
-	if err != nil {
-		return err 
+func (c *Context) queryValues() url.Values {
+	if c.cacheQueryValues == nil {
+		// query 每次都会重新解析
+		c.cacheQueryValues = c.Req.URL.Query()
 	}
-	c.Resp.WriteHeader(code)
-	_, err = c.Resp.Write(data)
-	return err
+	return c.cacheQueryValues
 }
 
+// HeaderValue 请求头中 key 对应的第一个值，key 不区分大小写
+func (c *Context) HeaderValue(key string) StringValue {
+	vals, err := c.HeaderValues(key)
+	if err != nil {
+		return StringValue{err: err}
+	}
+	return StringValue{val: vals[0]}
+}
 
+// HeaderValues 请求头中 key 对应的所有值，key 不区分大小写
+func (c *Context) HeaderValues(key string) ([]string, error) {
+	return lookupValues(c.Req.Header, textproto.CanonicalMIMEHeaderKey(key))
+}
 
-type StringValue struct {
-	val string 
-	err error
+func lookupValues(values map[string][]string, key string) ([]string, error) {
+	vals, ok := values[key]
+	if !ok || len(vals) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return vals, nil
 }
 
-func (s StringValue) String() (string, error) {
-	return s.val, s.err
+func (c *Context) PathValue(key string) StringValue {
+	val, ok := c.PathParams[key]
+	if !ok {
+		return StringValue{err: ErrKeyNotFound}
+	}
+	return StringValue{val: val}
 }
 
+
+
+
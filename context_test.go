@@ -0,0 +1,92 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestContext_QueryValues(t *testing.T) {
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/?tag=a&tag=b&name=Tom", nil)}
+	tags, err := ctx.QueryValues("tag")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, tags)
+	name, err := ctx.QueryValue("name").String()
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", name)
+	_, err = ctx.QueryValues("missing")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestContext_FormValues(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	require.NoError(t, mw.WriteField("hobby", "reading"))
+	require.NoError(t, mw.WriteField("hobby", "coding"))
+	require.NoError(t, mw.Close())
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "urlencoded",
+			contentType: MIMEForm,
+			body:        url.Values{"hobby": {"reading", "coding"}}.Encode(),
+		},
+		{
+			name:        "multipart",
+			contentType: mw.FormDataContentType(),
+			body:        body.String(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/?from=query", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			ctx := &Context{Req: req}
+
+			hobbies, err := ctx.FormValues("hobby")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"reading", "coding"}, hobbies)
+			// 表单已经缓存了，再次读取不需要重新解析请求体
+			hobby, err := ctx.FormValue("hobby").String()
+			require.NoError(t, err)
+			assert.Equal(t, "reading", hobby)
+
+			// FormValue 包括查询参数，而 PostFormValue 不包括
+			from, err := ctx.FormValue("from").String()
+			require.NoError(t, err)
+			assert.Equal(t, "query", from)
+			_, err = ctx.PostFormValue("from").String()
+			assert.True(t, errors.Is(err, ErrKeyNotFound))
+			hobby, err = ctx.PostFormValue("hobby").String()
+			require.NoError(t, err)
+			assert.Equal(t, "reading", hobby)
+		})
+	}
+}
+
+func TestContext_HeaderValues(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("Accept-Language", "zh")
+	req.Header.Add("Accept-Language", "en")
+	ctx := &Context{Req: req}
+
+	vals, err := ctx.HeaderValues("accept-language")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zh", "en"}, vals)
+	val, err := ctx.HeaderValue("ACCEPT-LANGUAGE").String()
+	require.NoError(t, err)
+	assert.Equal(t, "zh", val)
+	_, err = ctx.HeaderValue("X-Token").String()
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
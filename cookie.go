@@ -0,0 +1,181 @@
+package web
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var errNoCookieKey = errors.New("web: 没有设置 cookie 密钥，请使用 ServerWithCookieKeys")
+
+// CookieTamperedError 签名或者密文校验失败
+// 要么 cookie 被篡改过，要么签名用的密钥已经不在 ServerWithCookieKeys 里面了
+type CookieTamperedError struct {
+	Name string
+}
+
+func (e *CookieTamperedError) Error() string {
+	return fmt.Sprintf("web: cookie %s 校验失败", e.Name)
+}
+
+// ServerWithCookieKeys 设置签名和加密 cookie 的密钥
+// 第一个密钥用于签名和加密，所有的密钥都会用于校验和解密，
+// 所以轮换密钥的时候，把新的密钥放在最前面，旧的密钥保留一段时间再移除
+func ServerWithCookieKeys(keys ...[]byte) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.cookieKeys = newCookieKeys(keys)
+	}
+}
+
+// cookieKeys 从用户的密钥派生出来的签名密钥和加密密钥，
+// 避免同一个密钥同时用于 HMAC 和 AES
+type cookieKeys struct {
+	hashKeys [][]byte
+	aeads    []cipher.AEAD
+}
+
+func newCookieKeys(keys [][]byte) *cookieKeys {
+	if len(keys) == 0 {
+		return nil
+	}
+	res := &cookieKeys{
+		hashKeys: make([][]byte, 0, len(keys)),
+		aeads:    make([]cipher.AEAD, 0, len(keys)),
+	}
+	for _, key := range keys {
+		res.hashKeys = append(res.hashKeys, deriveKey(key, "web-cookie-sign"))
+		// 派生出来的密钥固定是 32 字节，也就是 AES-256，不会出错
+		block, _ := aes.NewCipher(deriveKey(key, "web-cookie-encrypt"))
+		aead, _ := cipher.NewGCM(block)
+		res.aeads = append(res.aeads, aead)
+	}
+	return res
+}
+
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// sign 签名的时候带上 cookie 的名字，防止把一个 cookie 的值挪到另外一个 cookie 上
+func sign(key []byte, name, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'='})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+var cookieEncoding = base64.RawURLEncoding
+
+// SetCookie 设置 cookie，直接写入响应头
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	http.SetCookie(c.Resp, cookie)
+}
+
+// Cookie 读取 cookie 的原始值
+func (c *Context) Cookie(name string) StringValue {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return StringValue{err: ErrKeyNotFound}
+	}
+	return StringValue{val: cookie.Value}
+}
+
+// SetSignedCookie 使用 HMAC-SHA256 签名 cookie.Value
+// 前端可以看到原始值，但是无法修改，需要保密的数据请使用 SetEncryptedCookie
+func (c *Context) SetSignedCookie(cookie *http.Cookie) error {
+	if c.cookieKeys == nil {
+		return errNoCookieKey
+	}
+	signed := *cookie
+	value := cookieEncoding.EncodeToString([]byte(cookie.Value))
+	signed.Value = value + "." + cookieEncoding.EncodeToString(sign(c.cookieKeys.hashKeys[0], cookie.Name, value))
+	c.SetCookie(&signed)
+	return nil
+}
+
+// SignedCookie 读取 SetSignedCookie 设置的 cookie，签名不对的时候返回 *CookieTamperedError
+func (c *Context) SignedCookie(name string) StringValue {
+	if c.cookieKeys == nil {
+		return StringValue{err: errNoCookieKey}
+	}
+	raw, err := c.Cookie(name).String()
+	if err != nil {
+		return StringValue{err: err}
+	}
+	tampered := StringValue{err: &CookieTamperedError{Name: name}}
+	value, sig, ok := strings.Cut(raw, ".")
+	if !ok {
+		return tampered
+	}
+	mac, err := cookieEncoding.DecodeString(sig)
+	if err != nil {
+		return tampered
+	}
+	for _, key := range c.cookieKeys.hashKeys {
+		if !hmac.Equal(mac, sign(key, name, value)) {
+			continue
+		}
+		data, err := cookieEncoding.DecodeString(value)
+		if err != nil {
+			return tampered
+		}
+		return StringValue{val: string(data)}
+	}
+	return tampered
+}
+
+// SetEncryptedCookie 使用 AES-GCM 加密 cookie.Value，前端既看不到也改不了
+func (c *Context) SetEncryptedCookie(cookie *http.Cookie) error {
+	if c.cookieKeys == nil {
+		return errNoCookieKey
+	}
+	aead := c.cookieKeys.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(cookie.Value)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	// cookie 的名字作为附加数据，同样是防止挪到别的 cookie 上
+	data := aead.Seal(nonce, nonce, []byte(cookie.Value), []byte(cookie.Name))
+	encrypted := *cookie
+	encrypted.Value = cookieEncoding.EncodeToString(data)
+	c.SetCookie(&encrypted)
+	return nil
+}
+
+// EncryptedCookie 读取 SetEncryptedCookie 设置的 cookie，解密失败的时候返回 *CookieTamperedError
+func (c *Context) EncryptedCookie(name string) StringValue {
+	if c.cookieKeys == nil {
+		return StringValue{err: errNoCookieKey}
+	}
+	raw, err := c.Cookie(name).String()
+	if err != nil {
+		return StringValue{err: err}
+	}
+	tampered := StringValue{err: &CookieTamperedError{Name: name}}
+	data, err := cookieEncoding.DecodeString(raw)
+	if err != nil {
+		return tampered
+	}
+	for _, aead := range c.cookieKeys.aeads {
+		if len(data) < aead.NonceSize() {
+			return tampered
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+		if err == nil {
+			return StringValue{val: string(plaintext)}
+		}
+	}
+	return tampered
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// roundTrip 用 set 设置 cookie，然后把响应里面的 cookie 带到新的请求上，再用 get 读取
+func roundTrip(t *testing.T, setKeys, getKeys *cookieKeys,
+	set func(ctx *Context) error, get func(ctx *Context) StringValue,
+	tamper func(cookie *http.Cookie)) (string, error) {
+	recorder := httptest.NewRecorder()
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil), cookieKeys: setKeys}
+	ctx.resp.reset(recorder)
+	ctx.Resp = &ctx.resp
+	require.NoError(t, set(ctx))
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	if tamper != nil {
+		tamper(cookies[0])
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	return get(&Context{Req: req, cookieKeys: getKeys}).String()
+}
+
+func TestContext_SignedAndEncryptedCookie(t *testing.T) {
+	oldKeys := newCookieKeys([][]byte{[]byte("old-key")})
+	rotatedKeys := newCookieKeys([][]byte{[]byte("new-key"), []byte("old-key")})
+	otherKeys := newCookieKeys([][]byte{[]byte("other-key")})
+
+	type method struct {
+		name string
+		set  func(ctx *Context, name string) error
+		get  func(ctx *Context) StringValue
+	}
+	methods := []method{
+		{
+			name: "signed",
+			set: func(ctx *Context, name string) error {
+				return ctx.SetSignedCookie(&http.Cookie{Name: name, Value: "Tom; 123"})
+			},
+			get: func(ctx *Context) StringValue { return ctx.SignedCookie("uid") },
+		},
+		{
+			name: "encrypted",
+			set: func(ctx *Context, name string) error {
+				return ctx.SetEncryptedCookie(&http.Cookie{Name: name, Value: "Tom; 123"})
+			},
+			get: func(ctx *Context) StringValue { return ctx.EncryptedCookie("uid") },
+		},
+	}
+	testCases := []struct {
+		name     string
+		setName  string
+		setKeys  *cookieKeys
+		getKeys  *cookieKeys
+		tamper   func(cookie *http.Cookie)
+		wantVal  string
+		tampered bool
+	}{
+		{
+			name:    "same key",
+			setKeys: oldKeys,
+			getKeys: oldKeys,
+			wantVal: "Tom; 123",
+		},
+		{
+			name:    "rotated key",
+			setKeys: oldKeys,
+			getKeys: rotatedKeys,
+			wantVal: "Tom; 123",
+		},
+		{
+			name:     "removed key",
+			setKeys:  oldKeys,
+			getKeys:  otherKeys,
+			tampered: true,
+		},
+		{
+			name:    "tampered value",
+			setKeys: oldKeys,
+			getKeys: oldKeys,
+			tamper: func(cookie *http.Cookie) {
+				cookie.Value = "X" + cookie.Value[1:]
+			},
+			tampered: true,
+		},
+		{
+			// 把其它 cookie 的值挪过来
+			name:    "renamed cookie",
+			setName: "admin",
+			setKeys: oldKeys,
+			getKeys: oldKeys,
+			tamper: func(cookie *http.Cookie) {
+				cookie.Name = "uid"
+			},
+			tampered: true,
+		},
+	}
+	for _, m := range methods {
+		for _, tc := range testCases {
+			t.Run(m.name+"/"+tc.name, func(t *testing.T) {
+				setName := tc.setName
+				if setName == "" {
+					setName = "uid"
+				}
+				set := func(ctx *Context) error { return m.set(ctx, setName) }
+				val, err := roundTrip(t, tc.setKeys, tc.getKeys, set, m.get, tc.tamper)
+				if tc.tampered {
+					var ce *CookieTamperedError
+					require.True(t, errors.As(err, &ce))
+					assert.Equal(t, "uid", ce.Name)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantVal, val)
+			})
+		}
+	}
+}
+
+func TestContext_Cookie(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	ctx := &Context{Req: req}
+	val, err := ctx.Cookie("theme").String()
+	require.NoError(t, err)
+	assert.Equal(t, "dark", val)
+	_, err = ctx.Cookie("lang").String()
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	assert.Equal(t, errNoCookieKey, ctx.SetSignedCookie(&http.Cookie{Name: "uid", Value: "1"}))
+	_, err = ctx.EncryptedCookie("theme").String()
+	assert.Equal(t, errNoCookieKey, err)
+}
+
+func TestServerWithCookieKeys(t *testing.T) {
+	s := NewHTTPServer(ServerWithCookieKeys([]byte("key")))
+	s.GET("/", HandleE(func(ctx *Context) error {
+		uid, err := ctx.SignedCookie("uid").String()
+		if err != nil {
+			return err
+		}
+		ctx.RespString(http.StatusOK, uid)
+		return nil
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "uid", Value: "MTIz.forged"})
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
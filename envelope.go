@@ -0,0 +1,262 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CodeOK 成功响应的业务码
+const CodeOK = 0
+
+// Envelope 统一的响应格式，例如
+//
+//	{"code":0,"msg":"ok","data":{"id":123}}
+//	{"code":10001,"msg":"余额不足","data":null}
+//
+// 框架自己产生的错误，例如绑定参数失败、校验失败，业务码就是对应的响应码
+type Envelope struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data any    `json:"data"`
+}
+
+// BizError 带业务码的 error，通常通过 ErrCodes.Register 定义为包变量，
+// 返回的时候再通过 Wrap 带上原因。Err 只会被记录到日志里面，不会返回给前端
+type BizError struct {
+	Code int
+	// Msg 没有注册对应语言的信息时使用的默认信息
+	Msg  string
+	Data any
+	Err  error
+}
+
+// NewBizError 创建没有注册过的业务错误，响应码是 400
+func NewBizError(code int, msg string) *BizError {
+	return &BizError{Code: code, Msg: msg}
+}
+
+// Wrap 返回一个新的 BizError，并且带上错误原因
+func (e *BizError) Wrap(err error) *BizError {
+	res := *e
+	res.Err = err
+	return &res
+}
+
+// WithData 返回一个新的 BizError，失败的时候也需要返回给前端的数据放在 data 里面
+func (e *BizError) WithData(data any) *BizError {
+	res := *e
+	res.Data = data
+	return &res
+}
+
+func (e *BizError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("web: 业务错误 %d %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("web: 业务错误 %d %s: %v", e.Code, e.Msg, e.Err)
+}
+
+func (e *BizError) Unwrap() error {
+	return e.Err
+}
+
+// Is 业务码一样就认为是同一个错误
+func (e *BizError) Is(target error) bool {
+	t, ok := target.(*BizError)
+	return ok && t.Code == e.Code
+}
+
+// ErrCodes 业务码的注册表，记录业务码对应的响应码以及各个语言的信息
+// 返回给前端的信息按照请求的 Accept-Language 选择，找不到的时候使用默认语言
+type ErrCodes struct {
+	defaultLang string
+	mu          sync.RWMutex
+	codes       map[int]errCode
+}
+
+type errCode struct {
+	status int
+	// msgs 语言 => 信息，语言统一是小写的
+	msgs map[string]string
+}
+
+// NewErrCodes defaultLang 是默认语言，例如 zh-CN
+func NewErrCodes(defaultLang string) *ErrCodes {
+	return &ErrCodes{
+		defaultLang: strings.ToLower(defaultLang),
+		codes:       make(map[int]errCode),
+	}
+}
+
+// Register 注册业务码，msgs 是语言到信息的映射，必须包含默认语言的信息，
+// 例如 codes.Register(10001, http.StatusForbidden, map[string]string{"zh-CN": "余额不足", "en": "insufficient balance"})
+// 也可以注册和响应码一样的业务码，这样框架自己产生的错误也能返回对应语言的信息
+// 和注册路由一样，重复注册或者信息不完整会直接 panic
+func (r *ErrCodes) Register(code int, status int, msgs map[string]string) *BizError {
+	if code == CodeOK {
+		panic(fmt.Sprintf("web: 业务码 %d 表示成功，不能注册为错误", CodeOK))
+	}
+	ec := errCode{status: status, msgs: make(map[string]string, len(msgs))}
+	for lang, msg := range msgs {
+		ec.msgs[strings.ToLower(lang)] = msg
+	}
+	msg, ok := ec.msgs[r.defaultLang]
+	if !ok {
+		panic(fmt.Sprintf("web: 业务码 %d 缺少默认语言 %s 的信息", code, r.defaultLang))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok = r.codes[code]; ok {
+		panic(fmt.Sprintf("web: 业务码 %d 重复注册", code))
+	}
+	r.codes[code] = ec
+	return &BizError{Code: code, Msg: msg}
+}
+
+// ServerWithErrCodes 设置所有路由共用的业务码注册表，
+// 这样使用 ServerWithErrorHandler(EnvelopeErrorHandler) 或者 DefaultErrorHandler 的时候，
+// BizError 也能得到注册的响应码和对应语言的信息
+func ServerWithErrCodes(codes *ErrCodes) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.errCodes = codes
+	}
+}
+
+// resolve 返回业务码对应的响应码和信息，没有注册的业务码返回 status 和 msg 本身
+func (r *ErrCodes) resolve(code int, status int, msg string, acceptLanguage string) (int, string) {
+	if r == nil {
+		return status, msg
+	}
+	r.mu.RLock()
+	ec, ok := r.codes[code]
+	r.mu.RUnlock()
+	if !ok {
+		return status, msg
+	}
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		if m, ok := ec.msgs[lang]; ok {
+			return ec.status, m
+		}
+		// zh-CN 找不到的时候退而求其次使用 zh
+		if primary, _, ok := strings.Cut(lang, "-"); ok {
+			if m, ok := ec.msgs[primary]; ok {
+				return ec.status, m
+			}
+		}
+	}
+	return ec.status, ec.msgs[r.defaultLang]
+}
+
+// parseAcceptLanguage 按照 q 值从大到小返回语言，q 为 0 的和 * 会被忽略
+func parseAcceptLanguage(header string) []string {
+	type langQ struct {
+		lang string
+		q    float64
+	}
+	var langs []langQ
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		key, val, _ := strings.Cut(strings.TrimSpace(params), "=")
+		if strings.ToLower(key) == "q" {
+			var err error
+			if q, err = strconv.ParseFloat(val, 64); err != nil {
+				q = 0
+			}
+		}
+		if q > 0 {
+			langs = append(langs, langQ{lang: lang, q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	res := make([]string, 0, len(langs))
+	for _, l := range langs {
+		res = append(res, l.lang)
+	}
+	return res
+}
+
+// Enveloped 返回的 middleware 让路由使用统一的响应格式：
+// HandleE 返回的 error 会通过 Fail 转化为 Envelope，而不是交给 HTTPServer 的 ErrorHandler
+// 通常用在路由分组上，例如
+//
+//	api := server.Group("/api", web.Enveloped(codes))
+//
+// codes 为 nil 的时候使用 ServerWithErrCodes 设置的注册表
+// 注意 404、405 之类没有命中路由的错误不会经过分组的 middleware，所以依旧是原来的响应格式
+func Enveloped(codes *ErrCodes) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.enveloped = true
+			if codes != nil {
+				ctx.errCodes = codes
+			}
+			ctx.errHandler = EnvelopeErrorHandler
+			next(ctx)
+		}
+	}
+}
+
+// EnvelopeErrorHandler 以 Envelope 的格式响应错误，
+// 也可以通过 ServerWithErrorHandler 让所有的路由都使用这种格式，这时候业务码的注册表通过 ServerWithErrCodes 设置
+func EnvelopeErrorHandler(ctx *Context, err error) {
+	_ = ctx.Fail(err)
+}
+
+// OK 以 Envelope 的格式返回成功响应
+func (c *Context) OK(data any) error {
+	return c.RespJSON(http.StatusOK, Envelope{Code: CodeOK, Msg: "ok", Data: data})
+}
+
+// Fail 以 Envelope 的格式返回错误响应，err 为 nil 的时候等价于 OK(nil)
+// BizError 的响应码和信息来自 Enveloped 或者 ServerWithErrCodes 设置的 ErrCodes，没有注册的业务码响应码是 400
+// 其它的 error 和 DefaultErrorHandler 一样转化为响应码，业务码就是响应码
+func (c *Context) Fail(err error) error {
+	if err == nil {
+		return c.OK(nil)
+	}
+	var (
+		status int
+		env    Envelope
+		logIt  bool
+	)
+	var be *BizError
+	var ve ValidationErrors
+	var p *Problem
+	if errors.As(err, &be) {
+		status, env = http.StatusBadRequest, Envelope{Code: be.Code, Msg: be.Msg, Data: be.Data}
+		logIt = be.Err != nil
+	} else if errors.As(err, &ve) {
+		status = http.StatusUnprocessableEntity
+		env = Envelope{Code: status, Msg: "参数校验失败", Data: ve.fields()}
+	} else if errors.As(err, &p) {
+		status = p.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		env = Envelope{Code: status, Msg: p.Detail, Data: p.Extensions}
+		if env.Msg == "" {
+			env.Msg = p.Title
+		}
+	} else {
+		status, env.Msg, logIt = errorStatus(err)
+		env.Code = status
+	}
+	status, env.Msg = c.errCodes.resolve(env.Code, status, env.Msg, c.Req.Header.Get("Accept-Language"))
+	if logIt || status >= http.StatusInternalServerError {
+		log.Printf("web: 处理请求 %s %s 失败: %v", c.Req.Method, c.Req.URL.Path, err)
+	}
+	return c.RespJSON(status, env)
+}
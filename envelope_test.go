@@ -0,0 +1,221 @@
+package web
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	codes := NewErrCodes("zh-CN")
+	errBalance := codes.Register(10001, http.StatusForbidden, map[string]string{
+		"zh-CN": "余额不足",
+		"en":    "insufficient balance",
+	})
+	codes.Register(http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, map[string]string{
+		"zh-CN": "参数不合法",
+		"en":    "invalid parameters",
+	})
+
+	s := NewHTTPServer()
+	api := s.Group("/api", Enveloped(codes))
+	api.GET("/ok", HandleE(func(ctx *Context) error {
+		return ctx.OK(map[string]int{"id": 123})
+	}))
+	api.GET("/balance", HandleE(func(ctx *Context) error {
+		return errBalance.Wrap(errors.New("余额 30")).WithData(map[string]int{"balance": 30})
+	}))
+	api.GET("/unregistered", HandleE(func(ctx *Context) error {
+		return NewBizError(20001, "未注册的业务错误")
+	}))
+	api.POST("/user", HandleE(func(ctx *Context) error {
+		var u struct {
+			Name string `json:"name" validate:"required"`
+		}
+		return ctx.Bind(&u)
+	}))
+	api.GET("/error", HandleE(func(ctx *Context) error {
+		return errors.New("db error")
+	}))
+	api.GET("/http", HandleE(func(ctx *Context) error {
+		return NewHTTPError(http.StatusNotFound, "用户不存在")
+	}))
+	// 没有使用 Enveloped 的路由不受影响
+	s.GET("/plain", HandleE(func(ctx *Context) error {
+		return errBalance
+	}))
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		lang     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "ok",
+			method:   http.MethodGet,
+			path:     "/api/ok",
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"ok","data":{"id":123}}`,
+		},
+		{
+			name:     "biz error",
+			method:   http.MethodGet,
+			path:     "/api/balance",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":10001,"msg":"余额不足","data":{"balance":30}}`,
+		},
+		{
+			name:     "biz error en",
+			method:   http.MethodGet,
+			path:     "/api/balance",
+			lang:     "fr;q=0.9, en-US, *;q=0.1",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":10001,"msg":"insufficient balance","data":{"balance":30}}`,
+		},
+		{
+			name:     "biz error unknown lang",
+			method:   http.MethodGet,
+			path:     "/api/balance",
+			lang:     "fr",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":10001,"msg":"余额不足","data":{"balance":30}}`,
+		},
+		{
+			name:     "unregistered",
+			method:   http.MethodGet,
+			path:     "/api/unregistered",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":20001,"msg":"未注册的业务错误","data":null}`,
+		},
+		{
+			name:     "bind error",
+			method:   http.MethodPost,
+			path:     "/api/user",
+			body:     `{"name":`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400,"msg":"Bad Request","data":null}`,
+		},
+		{
+			name:     "validation error",
+			method:   http.MethodPost,
+			path:     "/api/user",
+			body:     `{}`,
+			lang:     "en",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `{"code":422,"msg":"invalid parameters","data":{"name":"不能为空"}}`,
+		},
+		{
+			name:     "internal error",
+			method:   http.MethodGet,
+			path:     "/api/error",
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":500,"msg":"Internal Server Error","data":null}`,
+		},
+		{
+			name:     "http error",
+			method:   http.MethodGet,
+			path:     "/api/http",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"code":404,"msg":"用户不存在","data":null}`,
+		},
+		{
+			// 没有使用 ServerWithErrCodes，找不到注册表
+			name:     "not enveloped",
+			method:   http.MethodGet,
+			path:     "/plain",
+			wantCode: http.StatusBadRequest,
+			wantBody: "余额不足",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", MIMEJSON)
+			req.Header.Set("Accept-Language", tc.lang)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if strings.HasPrefix(tc.wantBody, "{") {
+				assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+				return
+			}
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestServerWithErrCodes(t *testing.T) {
+	codes := NewErrCodes("zh-CN")
+	errBalance := codes.Register(10001, http.StatusForbidden, map[string]string{
+		"zh-CN": "余额不足",
+		"en":    "insufficient balance",
+	})
+	testCases := []struct {
+		name     string
+		opts     []HTTPServerOption
+		lang     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "envelope error handler",
+			opts:     []HTTPServerOption{ServerWithErrorHandler(EnvelopeErrorHandler)},
+			lang:     "en",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"code":10001,"msg":"insufficient balance","data":null}`,
+		},
+		{
+			name:     "default error handler",
+			lang:     "en",
+			wantCode: http.StatusForbidden,
+			wantBody: "insufficient balance",
+		},
+		{
+			name:     "default error handler default lang",
+			wantCode: http.StatusForbidden,
+			wantBody: "余额不足",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHTTPServer(append(tc.opts, ServerWithErrCodes(codes))...)
+			s.GET("/balance", HandleE(func(ctx *Context) error {
+				return errBalance
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/balance", nil)
+			req.Header.Set("Accept-Language", tc.lang)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if strings.HasPrefix(tc.wantBody, "{") {
+				assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+				return
+			}
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestErrCodes_Register(t *testing.T) {
+	codes := NewErrCodes("zh-CN")
+	err := codes.Register(10001, http.StatusForbidden, map[string]string{"zh-cn": "余额不足"})
+	assert.Equal(t, &BizError{Code: 10001, Msg: "余额不足"}, err)
+	assert.True(t, errors.Is(err.Wrap(errors.New("余额 30")), err))
+
+	assert.Panics(t, func() {
+		codes.Register(10001, http.StatusForbidden, map[string]string{"zh-CN": "重复"})
+	})
+	assert.Panics(t, func() {
+		codes.Register(10002, http.StatusForbidden, map[string]string{"en": "no default"})
+	})
+	assert.Panics(t, func() {
+		codes.Register(CodeOK, http.StatusOK, map[string]string{"zh-CN": "成功"})
+	})
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// HandleFuncE 和 HandleFunc 一样是业务逻辑，区别在于可以直接返回 error，
+// 返回的 error 会交给 HTTPServer 的 ErrorHandler 统一转化为响应
+type HandleFuncE func(ctx *Context) error
+
+// ErrorHandler 将 HandleFuncE 返回的 error 转化为响应
+type ErrorHandler func(ctx *Context, err error)
+
+// HandleE 将 HandleFuncE 转化为 HandleFunc，这样就可以和 HandleFunc 一样注册路由
+// 例如 server.GET("/user", web.HandleE(func(ctx *web.Context) error {...}))
+func HandleE(fn HandleFuncE) HandleFunc {
+	return func(ctx *Context) {
+		err := fn(ctx)
+		if err == nil {
+			return
+		}
+		if ctx.errHandler == nil {
+			// 没有经过 HTTPServer 创建的 Context，例如测试里面手动创建的
+			DefaultErrorHandler(ctx, err)
+			return
+		}
+		ctx.errHandler(ctx, err)
+	}
+}
+
+// HTTPError 携带了响应码的 error
+// Msg 是返回给前端的信息，而 Err 是真正的错误原因，只会被记录到日志里面，不会返回给前端
+type HTTPError struct {
+	Code int
+	Msg  string
+	Err  error
+}
+
+// NewHTTPError 创建 HTTPError，msg 为空的时候使用响应码对应的默认描述
+func NewHTTPError(code int, msg string) *HTTPError {
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	return &HTTPError{Code: code, Msg: msg}
+}
+
+// Wrap 返回一个新的 HTTPError，并且带上错误原因
+// 这样可以把 HTTPError 定义为包变量，在返回的时候再带上原因
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	return &HTTPError{Code: e.Code, Msg: e.Msg, Err: err}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("web: %d %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("web: %d %s: %v", e.Code, e.Msg, e.Err)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Is 响应码和信息一样，就认为是同一个错误，不管原因是什么
+func (e *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Code == e.Code && t.Msg == e.Msg
+}
+
+// ServerWithErrorHandler 设置统一的错误处理
+func ServerWithErrorHandler(hdl ErrorHandler) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.errHandler = hdl
+	}
+}
+
+// DefaultErrorHandler 默认的错误处理
+// Problem 直接以 application/problem+json 响应，
+// BizError 使用 ServerWithErrCodes 注册的响应码和对应语言的信息，没有注册的是 400，
+// HTTPError 使用其中的响应码和信息，请求体太大是 413，绑定参数失败和 cookie 被篡改是 400，
+// 校验失败是 422 并且返回每个字段的错误信息，其它的错误一律认为是 500，
+// 错误原因只会被记录到日志里面。客户端接受 application/problem+json 的时候，以 problem details 响应
+func DefaultErrorHandler(ctx *Context, err error) {
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		respValidationErrors(ctx, ve)
+		return
+	}
+	var p *Problem
+	if errors.As(err, &p) {
+		if p.Status == 0 || p.Status >= http.StatusInternalServerError {
+			log.Printf("web: 处理请求 %s %s 失败: %v", ctx.Req.Method, ctx.Req.URL.Path, err)
+		}
+		_ = ctx.RespProblem(p)
+		return
+	}
+	var be *BizError
+	if errors.As(err, &be) {
+		code, msg := ctx.errCodes.resolve(be.Code, http.StatusBadRequest, be.Msg, ctx.Req.Header.Get("Accept-Language"))
+		if be.Err != nil || code >= http.StatusInternalServerError {
+			log.Printf("web: 处理请求 %s %s 失败: %v", ctx.Req.Method, ctx.Req.URL.Path, err)
+		}
+		ctx.respError(code, msg)
+		return
+	}
+	code, msg, logIt := errorStatus(err)
+	if logIt {
+		log.Printf("web: 处理请求 %s %s 失败: %v", ctx.Req.Method, ctx.Req.URL.Path, err)
+	}
+	ctx.respError(code, msg)
+}
+
+// errorStatus 把 ValidationErrors 和 Problem 以外的 error 转化为响应码和返回给前端的信息，
+// logIt 表示是否需要记录日志
+func errorStatus(err error) (code int, msg string, logIt bool) {
+	code = http.StatusInternalServerError
+	msg = http.StatusText(code)
+	var he *HTTPError
+	var be *BindError
+	var ce *CookieTamperedError
+	var me *http.MaxBytesError
+	if errors.As(err, &he) {
+		code, msg = he.Code, he.Msg
+	} else if errors.As(err, &me) {
+		// 需要在 BindError 之前判断，因为绑定的时候读取请求体失败也会被包装成 BindError
+		he = ErrRequestEntityTooLarge
+		code, msg = he.Code, he.Msg
+	} else if errors.As(err, &be) {
+		he = NewHTTPError(http.StatusBadRequest, "").Wrap(be)
+		code, msg = he.Code, he.Msg
+	} else if errors.As(err, &ce) {
+		he = NewHTTPError(http.StatusBadRequest, "").Wrap(ce)
+		code, msg = he.Code, he.Msg
+	}
+	logIt = code >= http.StatusInternalServerError || he == nil || he.Err != nil
+	return code, msg, logIt
+}
+
+// respValidationErrors 校验失败的响应，例如
+// {"msg":"参数校验失败","fields":{"email":"不是合法的邮箱","name":"不能为空"}}
+func respValidationErrors(ctx *Context, errs ValidationErrors) {
+	fields := errs.fields()
+	if ctx.AcceptsProblem() {
+		p := NewProblem(http.StatusUnprocessableEntity, "参数校验失败").With("fields", fields)
+		p.Instance = ctx.Req.URL.Path
+		_ = ctx.RespProblem(p)
+		return
+	}
+	_ = ctx.RespJSON(http.StatusUnprocessableEntity, map[string]any{
+		"msg":    "参数校验失败",
+		"fields": fields,
+	})
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+var errUserNotFound = NewHTTPError(http.StatusNotFound, "用户不存在")
+
+func TestHandleE(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	s := NewHTTPServer()
+	s.GET("/ok", HandleE(func(ctx *Context) error {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("ok")
+		return nil
+	}))
+	s.GET("/user/:id", HandleE(func(ctx *Context) error {
+		return errUserNotFound.Wrap(errors.New("sql: no rows in result set"))
+	}))
+	s.GET("/internal", HandleE(func(ctx *Context) error {
+		return fmt.Errorf("查询数据库失败 %w", errors.New("dial tcp: connection refused"))
+	}))
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+		wantLog  string
+	}{
+		{
+			name:     "no error",
+			path:     "/ok",
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+		{
+			name:     "http error",
+			path:     "/user/123",
+			wantCode: http.StatusNotFound,
+			wantBody: "用户不存在",
+			wantLog:  "sql: no rows in result set",
+		},
+		{
+			name:     "internal error",
+			path:     "/internal",
+			wantCode: http.StatusInternalServerError,
+			wantBody: "Internal Server Error",
+			wantLog:  "dial tcp: connection refused",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			// 错误原因只能出现在日志里面
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Contains(t, buf.String(), tc.wantLog)
+		})
+	}
+}
+
+func TestServerWithErrorHandler(t *testing.T) {
+	s := NewHTTPServer(ServerWithErrorHandler(func(ctx *Context, err error) {
+		ctx.RespStatusCode = http.StatusTeapot
+		ctx.RespData = []byte(err.Error())
+	}))
+	s.GET("/", HandleE(func(ctx *Context) error {
+		return errors.New("mock error")
+	}))
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTeapot, recorder.Code)
+	assert.Equal(t, "mock error", recorder.Body.String())
+}
+
+func TestHTTPError_Is(t *testing.T) {
+	err := fmt.Errorf("查询用户: %w", errUserNotFound.Wrap(errors.New("sql: no rows in result set")))
+	assert.True(t, errors.Is(err, errUserNotFound))
+	assert.False(t, errors.Is(err, NewHTTPError(http.StatusNotFound, "")))
+	assert.Equal(t, "web: 404 用户不存在: sql: no rows in result set", errors.Unwrap(err).Error())
+}
@@ -187,9 +187,10 @@ func (h *StaticResourceHandler) readFileFromData(fileName string) (*fileCacheIte
 
 func (h *StaticResourceHandler) writeItemAsResponse(item *fileCacheItem, writer http.ResponseWriter) {
 	// 直接将缓存中的数据写回到 response
-	writer.WriteHeader(http.StatusOK)
+	// 响应头必须在 WriteHeader 之前设置，否则不会生效
 	writer.Header().Set("Content-Type", item.contentType)
 	writer.Header().Set("Content-Length", fmt.Sprintf("%d", item.fileSize))
+	writer.WriteHeader(http.StatusOK)
 	_, _ = writer.Write(item.data)
 }
 
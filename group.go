@@ -0,0 +1,96 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RouteGroup 共享路径前缀和 middleware 的一组路由，例如
+//
+//	api := server.Group("/api", authMdl)
+//	v1 := api.Group("/v1")
+//	v1.GET("/users/:id", getUser) // 实际注册的是 /api/v1/users/:id
+//
+// 分组的 middleware 在注册路由的时候就组装到 handler 上，
+// 所以只对命中了路由的请求生效，并且在 HTTPServer 的 middleware 之后执行
+type RouteGroup struct {
+	server *HTTPServer
+	prefix string
+	mdls   []Middleware
+}
+
+// Group 创建路由分组，prefix 必须以 / 开头并且结尾不能有 /，为空表示不加前缀
+func (h *HTTPServer) Group(prefix string, mdls ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		server: h,
+		prefix: checkPrefix(prefix),
+		mdls:   mdls,
+	}
+}
+
+// Group 创建子分组，子分组继承当前分组的前缀和 middleware
+func (g *RouteGroup) Group(prefix string, mdls ...Middleware) *RouteGroup {
+	res := make([]Middleware, 0, len(g.mdls)+len(mdls))
+	res = append(res, g.mdls...)
+	return &RouteGroup{
+		server: g.server,
+		prefix: g.prefix + checkPrefix(prefix),
+		mdls:   append(res, mdls...),
+	}
+}
+
+// Use 给分组添加 middleware，只对之后注册的路由生效
+func (g *RouteGroup) Use(mdls ...Middleware) {
+	g.mdls = append(g.mdls, mdls...)
+}
+
+// Prefix 分组的完整路径前缀
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// Handle 注册路由，path 为 / 的时候注册的就是分组前缀本身
+func (g *RouteGroup) Handle(method string, path string, handler HandleFunc, opts ...RouteOption) {
+	full := g.prefix + path
+	if path == "/" && g.prefix != "" {
+		full = g.prefix
+	}
+	for i := len(g.mdls) - 1; i >= 0; i-- {
+		handler = g.mdls[i](handler)
+	}
+	g.server.addRoute(method, full, handler, opts...)
+}
+
+func (g *RouteGroup) GET(path string, handler HandleFunc, opts ...RouteOption) {
+	g.Handle(http.MethodGet, path, handler, opts...)
+}
+
+func (g *RouteGroup) POST(path string, handler HandleFunc, opts ...RouteOption) {
+	g.Handle(http.MethodPost, path, handler, opts...)
+}
+
+func (g *RouteGroup) PUT(path string, handler HandleFunc, opts ...RouteOption) {
+	g.Handle(http.MethodPut, path, handler, opts...)
+}
+
+func (g *RouteGroup) PATCH(path string, handler HandleFunc, opts ...RouteOption) {
+	g.Handle(http.MethodPatch, path, handler, opts...)
+}
+
+func (g *RouteGroup) DELETE(path string, handler HandleFunc, opts ...RouteOption) {
+	g.Handle(http.MethodDelete, path, handler, opts...)
+}
+
+// checkPrefix "/" 和空字符串一样，表示不加前缀
+func checkPrefix(prefix string) string {
+	if prefix == "" || prefix == "/" {
+		return ""
+	}
+	if prefix[0] != '/' {
+		panic(fmt.Sprintf("web: 分组前缀必须以 / 开头 [%s]", prefix))
+	}
+	if prefix[len(prefix)-1] == '/' {
+		panic(fmt.Sprintf("web: 分组前缀不能以 / 结尾 [%s]", prefix))
+	}
+	return prefix
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteGroup(t *testing.T) {
+	var trace []string
+	mdl := func(name string) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				trace = append(trace, name)
+				next(ctx)
+			}
+		}
+	}
+	handler := func(ctx *Context) {
+		ctx.RespString(http.StatusOK, ctx.MatchedRoute)
+	}
+	s := NewHTTPServer(ServerWithMiddleware(mdl("server")))
+	api := s.Group("/api", mdl("api"))
+	api.GET("/", handler)
+	v1 := api.Group("/v1", mdl("v1"))
+	v1.Use(mdl("v1-use"))
+	v1.GET("/users/:id", handler)
+	v1.PUT("/users/:id", handler)
+	v1.DELETE("/users/:id", handler)
+	// 子分组不影响父分组
+	api.PATCH("/users/:id", handler)
+	s.Group("/").POST("/login", handler)
+
+	testCases := []struct {
+		name      string
+		method    string
+		path      string
+		wantRoute string
+		wantTrace []string
+	}{
+		{
+			name:      "group root",
+			method:    http.MethodGet,
+			path:      "/api",
+			wantRoute: "/api",
+			wantTrace: []string{"server", "api"},
+		},
+		{
+			name:      "nested",
+			method:    http.MethodGet,
+			path:      "/api/v1/users/123",
+			wantRoute: "/api/v1/users/:id",
+			wantTrace: []string{"server", "api", "v1", "v1-use"},
+		},
+		{
+			name:      "nested put",
+			method:    http.MethodPut,
+			path:      "/api/v1/users/123",
+			wantRoute: "/api/v1/users/:id",
+			wantTrace: []string{"server", "api", "v1", "v1-use"},
+		},
+		{
+			name:      "nested delete",
+			method:    http.MethodDelete,
+			path:      "/api/v1/users/123",
+			wantRoute: "/api/v1/users/:id",
+			wantTrace: []string{"server", "api", "v1", "v1-use"},
+		},
+		{
+			name:      "parent",
+			method:    http.MethodPatch,
+			path:      "/api/users/123",
+			wantRoute: "/api/users/:id",
+			wantTrace: []string{"server", "api"},
+		},
+		{
+			name:      "no prefix",
+			method:    http.MethodPost,
+			path:      "/login",
+			wantRoute: "/login",
+			wantTrace: []string{"server"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trace = nil
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantRoute, recorder.Body.String())
+			assert.Equal(t, tc.wantTrace, trace)
+		})
+	}
+
+	assert.Equal(t, "/api/v1", v1.Prefix())
+	assert.Panics(t, func() { s.Group("api") })
+	assert.Panics(t, func() { s.Group("/api/") })
+}
@@ -0,0 +1,378 @@
+package web
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSlowSubscriber 订阅者消费太慢，被 Hub 断开了，只在 HubDropSubscriber 策略下出现
+var ErrSlowSubscriber = errors.New("web: 订阅者消费太慢，已经被断开")
+
+// SSEEvent 一个 Server-Sent Events 事件
+type SSEEvent struct {
+	// Event 事件类型，为空的时候客户端按照 message 处理
+	Event string
+	// ID 为空的时候，Hub 会自动生成一个递增的 ID
+	ID   string
+	Data any
+}
+
+// SendEvent 和 Send 一样，只是参数换成了 SSEEvent
+func (s *SSEStream) SendEvent(e SSEEvent) error {
+	return s.Send(e.Event, e.ID, e.Data)
+}
+
+// SlowPolicy 订阅者的缓冲区满了之后怎么办
+type SlowPolicy int
+
+const (
+	// HubDropEvents 丢弃这个订阅者放不下的事件，订阅者依旧保持连接
+	HubDropEvents SlowPolicy = iota
+	// HubDropSubscriber 直接断开这个订阅者，客户端重连的时候可以通过 Last-Event-ID 补上错过的事件
+	HubDropSubscriber
+)
+
+// Hub 按照 topic 把事件推送给多个 SSE 连接，例如
+//
+//	hub := web.NewHub(web.HubWithReplay(100))
+//	server.GET("/orders/:id/events", func(ctx *web.Context) {
+//		_ = hub.Subscribe(ctx, "order:"+ctx.PathParams["id"])
+//	})
+//	// 其它地方
+//	hub.Publish("order:123", web.SSEEvent{Event: "paid", Data: order})
+//
+// Hub 只在当前进程内推送，多实例部署的时候需要自己通过消息队列之类的转发到每个实例
+type Hub struct {
+	bufferSize int
+	replaySize int
+	replayTTL  time.Duration
+	policy     SlowPolicy
+	heartbeat  time.Duration
+
+	mu     sync.RWMutex
+	topics map[string]*hubTopic
+	// seq 每个事件的全局序号，用于 Last-Event-ID 重放的时候合并多个 topic 的事件
+	seq uint64
+	// lastPrune 上一次清理过期 topic 的时间
+	lastPrune time.Time
+
+	published    atomic.Uint64
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+}
+
+type HubOption func(h *Hub)
+
+// HubWithBufferSize 每个订阅者最多缓存多少个还没有发送的事件，默认是 64
+func HubWithBufferSize(n int) HubOption {
+	return func(h *Hub) {
+		h.bufferSize = n
+	}
+}
+
+// HubWithReplay 每个 topic 保留最近的 n 个事件，
+// 客户端带着 Last-Event-ID 重连的时候，会先补发这个 ID 之后的事件，默认不保留
+func HubWithReplay(n int) HubOption {
+	return func(h *Hub) {
+		h.replaySize = n
+	}
+}
+
+// HubWithReplayTTL 没有订阅者的 topic 超过 ttl 没有新的事件，就连同保留的事件一起清理掉，
+// 避免 topic 很多的时候内存一直增长，默认是 10 分钟，小于等于 0 表示一直保留
+func HubWithReplayTTL(ttl time.Duration) HubOption {
+	return func(h *Hub) {
+		h.replayTTL = ttl
+	}
+}
+
+// HubWithSlowPolicy 设置订阅者缓冲区满了之后的处理策略，默认是 HubDropEvents
+func HubWithSlowPolicy(policy SlowPolicy) HubOption {
+	return func(h *Hub) {
+		h.policy = policy
+	}
+}
+
+// HubWithHeartbeat 每个订阅连接都按照 interval 发送心跳，默认不发送
+func HubWithHeartbeat(interval time.Duration) HubOption {
+	return func(h *Hub) {
+		h.heartbeat = interval
+	}
+}
+
+func NewHub(opts ...HubOption) *Hub {
+	h := &Hub{
+		bufferSize: 64,
+		replayTTL:  10 * time.Minute,
+		topics:     make(map[string]*hubTopic),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// hubEvent 带上全局序号的事件
+type hubEvent struct {
+	seq uint64
+	SSEEvent
+}
+
+type hubTopic struct {
+	subs map[*hubSubscriber]struct{}
+	// ring 最近的事件，从 start 开始的 size 个是有效的
+	ring  []hubEvent
+	start int
+	size  int
+	// lastPublish 最近一次推送事件的时间
+	lastPublish time.Time
+}
+
+func (t *hubTopic) record(e hubEvent) {
+	if len(t.ring) == 0 {
+		return
+	}
+	if t.size < len(t.ring) {
+		t.ring[(t.start+t.size)%len(t.ring)] = e
+		t.size++
+		return
+	}
+	t.ring[t.start] = e
+	t.start = (t.start + 1) % len(t.ring)
+}
+
+func (t *hubTopic) recent() []hubEvent {
+	res := make([]hubEvent, 0, t.size)
+	for i := 0; i < t.size; i++ {
+		res = append(res, t.ring[(t.start+i)%len(t.ring)])
+	}
+	return res
+}
+
+type hubSubscriber struct {
+	events chan hubEvent
+	// topics 订阅的 topic，取消订阅的时候只需要处理这些 topic
+	topics []string
+	// kicked 被 HubDropSubscriber 策略断开的时候关闭
+	kicked   chan struct{}
+	kickOnce sync.Once
+}
+
+func (s *hubSubscriber) kick() {
+	s.kickOnce.Do(func() {
+		close(s.kicked)
+	})
+}
+
+// topic 找不到的时候会创建，调用者必须持有写锁
+func (h *Hub) topic(name string) *hubTopic {
+	t, ok := h.topics[name]
+	if !ok {
+		t = &hubTopic{
+			subs: make(map[*hubSubscriber]struct{}),
+			ring: make([]hubEvent, h.replaySize),
+		}
+		h.topics[name] = t
+	}
+	return t
+}
+
+// Publish 把事件推送给 topic 的所有订阅者，不会因为某个订阅者太慢而阻塞
+func (h *Hub) Publish(topic string, event SSEEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(h.seq, 10)
+	}
+	e := hubEvent{seq: h.seq, SSEEvent: event}
+	h.published.Add(1)
+	now := time.Now()
+	h.pruneLocked(now)
+	t, ok := h.topics[topic]
+	if !ok {
+		// 没有订阅者，也不需要保留事件，那么就不用创建 topic
+		if h.replaySize <= 0 {
+			return
+		}
+		t = h.topic(topic)
+	}
+	t.record(e)
+	t.lastPublish = now
+	for sub := range t.subs {
+		select {
+		case sub.events <- e:
+		default:
+			h.dropped.Add(1)
+			if h.policy == HubDropSubscriber {
+				h.removeLocked(sub)
+				h.disconnected.Add(1)
+				sub.kick()
+			}
+		}
+	}
+}
+
+// Subscribe 把当前连接作为 SSE 订阅者，订阅 topics 里面的事件，直到客户端断开连接
+// 如果请求带了 Last-Event-ID，那么先补发保留的事件中这个 ID 之后的事件，
+// 找不到这个 ID 的时候（例如太久了，已经被挤出去了）补发所有保留的事件
+// 客户端断开连接的时候返回 nil，被 HubDropSubscriber 策略断开的时候返回 ErrSlowSubscriber
+func (h *Hub) Subscribe(ctx *Context, topics ...string) error {
+	stream, err := ctx.SSE()
+	if err != nil {
+		return err
+	}
+	sub := &hubSubscriber{
+		events: make(chan hubEvent, h.bufferSize),
+		topics: topics,
+		kicked: make(chan struct{}),
+	}
+	// 在同一个锁里面注册和计算需要补发的事件，保证补发的事件和之后推送的事件不重不漏
+	h.mu.Lock()
+	replay := h.replayLocked(ctx.Req.Header.Get("Last-Event-ID"), topics)
+	for _, name := range topics {
+		h.topic(name).subs[sub] = struct{}{}
+	}
+	h.mu.Unlock()
+	defer h.remove(sub)
+
+	if h.heartbeat > 0 {
+		stream.Heartbeat(h.heartbeat)
+	}
+	for _, e := range replay {
+		if err = stream.SendEvent(e.SSEEvent); err != nil {
+			return h.subscribeErr(err)
+		}
+	}
+	for {
+		select {
+		case e := <-sub.events:
+			if err = stream.SendEvent(e.SSEEvent); err != nil {
+				return h.subscribeErr(err)
+			}
+		case <-sub.kicked:
+			return ErrSlowSubscriber
+		case <-stream.Done():
+			return nil
+		}
+	}
+}
+
+// subscribeErr 客户端断开连接不算错误
+func (h *Hub) subscribeErr(err error) error {
+	if errors.Is(err, ErrSSEClosed) {
+		return nil
+	}
+	return err
+}
+
+// replayLocked 调用者必须持有锁
+func (h *Hub) replayLocked(lastEventID string, topics []string) []hubEvent {
+	if lastEventID == "" || h.replaySize <= 0 {
+		return nil
+	}
+	var events []hubEvent
+	var lastSeq uint64
+	now := time.Now()
+	for _, name := range topics {
+		t, ok := h.topics[name]
+		if !ok || h.expired(t, now) {
+			continue
+		}
+		for _, e := range t.recent() {
+			if e.ID == lastEventID {
+				lastSeq = e.seq
+			}
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].seq < events[j].seq
+	})
+	res := events[:0]
+	for _, e := range events {
+		if e.seq > lastSeq {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (h *Hub) remove(sub *hubSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(sub)
+}
+
+// removeLocked 取消订阅，没有订阅者也没有保留事件的 topic 会被删除，调用者必须持有写锁
+func (h *Hub) removeLocked(sub *hubSubscriber) {
+	for _, name := range sub.topics {
+		t, ok := h.topics[name]
+		if !ok {
+			continue
+		}
+		delete(t.subs, sub)
+		if len(t.subs) == 0 && t.size == 0 {
+			delete(h.topics, name)
+		}
+	}
+}
+
+// pruneLocked 删除过期的 topic，每个 replayTTL 最多扫描一次，调用者必须持有写锁
+func (h *Hub) pruneLocked(now time.Time) {
+	if h.replayTTL <= 0 || now.Sub(h.lastPrune) < h.replayTTL {
+		return
+	}
+	h.lastPrune = now
+	for name, t := range h.topics {
+		if h.expired(t, now) {
+			delete(h.topics, name)
+		}
+	}
+}
+
+// expired 没有订阅者并且超过 replayTTL 没有新的事件
+func (h *Hub) expired(t *hubTopic, now time.Time) bool {
+	return h.replayTTL > 0 && len(t.subs) == 0 && now.Sub(t.lastPublish) >= h.replayTTL
+}
+
+// HubStats Hub 的统计数据
+type HubStats struct {
+	// Subscribers 当前的订阅连接数，订阅了多个 topic 的连接只算一个
+	Subscribers int
+	// TopicSubscribers 每个 topic 当前的订阅者数量
+	TopicSubscribers map[string]int
+	// Published 累计推送的事件数
+	Published uint64
+	// Dropped 累计因为订阅者缓冲区满了而没有送达的事件数
+	Dropped uint64
+	// Disconnected 累计因为消费太慢而被断开的订阅者数
+	Disconnected uint64
+}
+
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	subs := make(map[*hubSubscriber]struct{})
+	topicSubs := make(map[string]int, len(h.topics))
+	for name, t := range h.topics {
+		if len(t.subs) == 0 {
+			continue
+		}
+		topicSubs[name] = len(t.subs)
+		for sub := range t.subs {
+			subs[sub] = struct{}{}
+		}
+	}
+	return HubStats{
+		Subscribers:      len(subs),
+		TopicSubscribers: topicSubs,
+		Published:        h.published.Load(),
+		Dropped:          h.dropped.Load(),
+		Disconnected:     h.disconnected.Load(),
+	}
+}
@@ -0,0 +1,234 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// subscribeHub 订阅 topics，返回读取下一个事件的方法
+func subscribeHub(t *testing.T, url string, lastEventID string) (func() string, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+	reader := bufio.NewReader(resp.Body)
+	return func() string {
+		var sb strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return sb.String()
+			}
+			sb.WriteString(line)
+		}
+	}, cancel
+}
+
+func TestHub_Publish(t *testing.T) {
+	hub := NewHub()
+	s := NewHTTPServer()
+	s.GET("/events", func(ctx *Context) {
+		_ = hub.Subscribe(ctx, ctx.Req.URL.Query()["topic"]...)
+	})
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	readA, cancelA := subscribeHub(t, server.URL+"/events?topic=a", "")
+	defer cancelA()
+	readAB, cancelAB := subscribeHub(t, server.URL+"/events?topic=a&topic=b", "")
+	defer cancelAB()
+	require.Eventually(t, func() bool {
+		return hub.Stats().Subscribers == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, hub.Stats().TopicSubscribers)
+
+	hub.Publish("a", SSEEvent{Event: "greeting", Data: "hello"})
+	hub.Publish("b", SSEEvent{ID: "custom", Data: map[string]int{"n": 1}})
+	assert.Equal(t, "event: greeting\nid: 1\ndata: hello\n", readA())
+	assert.Equal(t, "event: greeting\nid: 1\ndata: hello\n", readAB())
+	assert.Equal(t, "id: custom\ndata: {\"n\":1}\n", readAB())
+
+	// 断开之后自动取消订阅
+	cancelAB()
+	require.Eventually(t, func() bool {
+		return hub.Stats().Subscribers == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]int{"a": 1}, hub.Stats().TopicSubscribers)
+	assert.Equal(t, uint64(2), hub.Stats().Published)
+}
+
+func TestHub_Replay(t *testing.T) {
+	testCases := []struct {
+		name        string
+		lastEventID string
+		want        []string
+	}{
+		{
+			// 1 到 3 已经被挤出去了，只保留了 3、4、5
+			name:        "replay after id",
+			lastEventID: "3",
+			want:        []string{"id: 4\ndata: a\n", "id: 5\ndata: a\n", "id: 6\ndata: b\n"},
+		},
+		{
+			name:        "unknown id",
+			lastEventID: "1",
+			want:        []string{"id: 3\ndata: a\n", "id: 4\ndata: a\n", "id: 5\ndata: a\n", "id: 6\ndata: b\n"},
+		},
+		{
+			name:        "up to date",
+			lastEventID: "6",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hub := NewHub(HubWithReplay(3))
+			s := NewHTTPServer()
+			s.GET("/events", func(ctx *Context) {
+				_ = hub.Subscribe(ctx, "a", "b")
+			})
+			server := httptest.NewServer(s)
+			defer server.Close()
+			for i := 0; i < 5; i++ {
+				hub.Publish("a", SSEEvent{Data: "a"})
+			}
+			hub.Publish("b", SSEEvent{Data: "b"})
+
+			read, cancel := subscribeHub(t, server.URL+"/events", tc.lastEventID)
+			defer cancel()
+			for _, want := range tc.want {
+				assert.Equal(t, want, read())
+			}
+			// 补发完成之后，继续收到新的事件
+			require.Eventually(t, func() bool {
+				return hub.Stats().Subscribers == 1
+			}, time.Second, 10*time.Millisecond)
+			hub.Publish("b", SSEEvent{Data: "live"})
+			assert.Equal(t, "id: 7\ndata: live\n", read())
+		})
+	}
+}
+
+func TestHub_SlowPolicy(t *testing.T) {
+	testCases := []struct {
+		name             string
+		policy           SlowPolicy
+		wantBuffered     int
+		wantSubscribers  int
+		wantDisconnected uint64
+	}{
+		{
+			name:            "drop events",
+			policy:          HubDropEvents,
+			wantBuffered:    2,
+			wantSubscribers: 1,
+		},
+		{
+			name:             "drop subscriber",
+			policy:           HubDropSubscriber,
+			wantBuffered:     2,
+			wantDisconnected: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hub := NewHub(HubWithBufferSize(2), HubWithSlowPolicy(tc.policy))
+			// 模拟一个一直不消费的订阅者
+			sub := &hubSubscriber{events: make(chan hubEvent, 2), topics: []string{"a"}, kicked: make(chan struct{})}
+			hub.mu.Lock()
+			hub.topic("a").subs[sub] = struct{}{}
+			hub.mu.Unlock()
+
+			for i := 0; i < 3; i++ {
+				hub.Publish("a", SSEEvent{Data: i})
+			}
+			assert.Len(t, sub.events, tc.wantBuffered)
+			stats := hub.Stats()
+			assert.Equal(t, tc.wantSubscribers, stats.Subscribers)
+			assert.Equal(t, uint64(1), stats.Dropped)
+			assert.Equal(t, tc.wantDisconnected, stats.Disconnected)
+			select {
+			case <-sub.kicked:
+				assert.Equal(t, HubDropSubscriber, tc.policy)
+			default:
+				assert.Equal(t, HubDropEvents, tc.policy)
+			}
+		})
+	}
+}
+
+func TestHub_Reclaim(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []HubOption
+		// wantTopics 推送之后立刻检查的 topic 数量
+		wantTopics int
+		// wait 等待多久之后再推送一次，触发清理
+		wait time.Duration
+	}{
+		{
+			name:       "no replay",
+			wantTopics: 0,
+		},
+		{
+			name:       "replay ttl",
+			opts:       []HubOption{HubWithReplay(10), HubWithReplayTTL(50 * time.Millisecond)},
+			wantTopics: 1000,
+			wait:       100 * time.Millisecond,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hub := NewHub(tc.opts...)
+			for i := 0; i < 1000; i++ {
+				hub.Publish("topic:"+strconv.Itoa(i), SSEEvent{Data: i})
+			}
+			assert.Len(t, hub.topics, tc.wantTopics)
+			if tc.wait > 0 {
+				time.Sleep(tc.wait)
+				hub.Publish("other", SSEEvent{Data: "trigger"})
+				assert.Len(t, hub.topics, 1)
+			}
+		})
+	}
+}
+
+func TestHub_ReclaimOnUnsubscribe(t *testing.T) {
+	hub := NewHub()
+	s := NewHTTPServer()
+	s.GET("/events", func(ctx *Context) {
+		_ = hub.Subscribe(ctx, ctx.Req.URL.Query()["topic"]...)
+	})
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	_, cancel := subscribeHub(t, server.URL+"/events?topic=a&topic=b", "")
+	require.Eventually(t, func() bool {
+		return hub.Stats().Subscribers == 1
+	}, time.Second, 10*time.Millisecond)
+	hub.mu.RLock()
+	assert.Len(t, hub.topics, 2)
+	hub.mu.RUnlock()
+
+	cancel()
+	require.Eventually(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		return len(hub.topics) == 0
+	}, time.Second, 10*time.Millisecond)
+}
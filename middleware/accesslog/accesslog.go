@@ -1,10 +1,9 @@
-//go:build v9
 package accesslog
 
 import (
 	"encoding/json"
 	"fmt"
-	web "github.com/Ai-feier/geek-web/v9"
+	web "github.com/Ai-feier/geek-web"
 )
 
 type MiddlewareBuilder struct {
@@ -29,7 +28,9 @@ func (b *MiddlewareBuilder) Build() web.Middleware {
 		return func(ctx *web.Context) {  // 返回 handlefunc
 			defer func() {
 				l := accessLog{
-					Host:       ctx.Req.Host,
+					Host:       ctx.Host(),
+					Scheme:     ctx.Scheme(),
+					ClientIP:   ctx.ClientIP(),
 					Route:      ctx.MatchedRoute,
 					HTTPMethod: ctx.Req.Method,
 					Path:       ctx.Req.URL.Path,
@@ -43,8 +44,12 @@ func (b *MiddlewareBuilder) Build() web.Middleware {
 	}
 }
 
+// accessLog 配置了 web.ServerWithTrustedProxies 之后，
+// Host、Scheme、ClientIP 都是客户端真实的，而不是负载均衡器的
 type accessLog struct {
 	Host       string
+	Scheme     string
+	ClientIP   string `json:"client_ip"`
 	Route      string
 	HTTPMethod string `json:"http_method"`
 	Path       string
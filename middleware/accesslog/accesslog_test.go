@@ -0,0 +1,41 @@
+package accesslog
+
+import (
+	"encoding/json"
+	web "github.com/Ai-feier/geek-web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	var log string
+	s := web.NewHTTPServer(
+		web.ServerWithTrustedProxies("10.0.0.0/8"),
+		web.ServerWithMiddleware(NewBuilder().LogFunc(func(accessLog string) {
+			log = accessLog
+		}).Build()))
+	s.GET("/user/:id", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/123", nil)
+	req.RemoteAddr = "10.0.0.1:43210"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "example.com")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	var l accessLog
+	require.NoError(t, json.Unmarshal([]byte(log), &l))
+	assert.Equal(t, accessLog{
+		Host:       "example.com",
+		Scheme:     "https",
+		ClientIP:   "203.0.113.7",
+		Route:      "/user/:id",
+		HTTPMethod: http.MethodGet,
+		Path:       "/user/123",
+	}, l)
+}
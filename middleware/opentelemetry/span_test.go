@@ -0,0 +1,86 @@
+package opentelemetry
+
+import (
+	web "github.com/Ai-feier/geek-web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareBuilder_Attributes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	builder := &MiddlewareBuilder{Tracer: provider.Tracer(instrumentationName)}
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		wantAttrs  map[attribute.Key]attribute.Value
+	}{
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.1:43210",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7, 10.0.0.2",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "api.example.com",
+			},
+			wantAttrs: map[attribute.Key]attribute.Value{
+				"peer.hostname":  attribute.StringValue("api.example.com"),
+				"http.scheme":    attribute.StringValue("https"),
+				"http.client_ip": attribute.StringValue("203.0.113.7"),
+				"http.status":    attribute.IntValue(http.StatusOK),
+			},
+		},
+		{
+			// 客户端伪造的请求头不会被使用
+			name:       "untrusted",
+			remoteAddr: "203.0.113.7:43210",
+			headers: map[string]string{
+				"X-Forwarded-For":   "1.1.1.1",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.com",
+			},
+			wantAttrs: map[attribute.Key]attribute.Value{
+				"peer.hostname":  attribute.StringValue("example.com"),
+				"http.scheme":    attribute.StringValue("http"),
+				"http.client_ip": attribute.StringValue("203.0.113.7"),
+				"http.status":    attribute.IntValue(http.StatusOK),
+			},
+		},
+	}
+	s := web.NewHTTPServer(
+		web.ServerWithTrustedProxies("10.0.0.0/8"),
+		web.ServerWithMiddleware(builder.Build()))
+	s.GET("/user/:id", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/user/123", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			s.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			require.NotEmpty(t, spans)
+			span := spans[len(spans)-1]
+			assert.Equal(t, "/user/:id", span.Name())
+			attrs := make(map[attribute.Key]attribute.Value)
+			for _, kv := range span.Attributes() {
+				attrs[kv.Key] = kv.Value
+			}
+			for k, v := range tc.wantAttrs {
+				assert.Equal(t, v, attrs[k], string(k))
+			}
+		})
+	}
+}
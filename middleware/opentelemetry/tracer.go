@@ -1,8 +1,7 @@
-//go:build v9
 package opentelemetry
 
 import (
-	web "github.com/Ai-feier/geek-web/v9"
+	web "github.com/Ai-feier/geek-web"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/propagation"
@@ -27,12 +26,13 @@ func (m *MiddlewareBuilder) Build() web.Middleware {
 			reqCtx, span := m.Tracer.Start(reqCtx, "unknown", trace.WithAttributes())
 
 			span.SetAttributes(attribute.String("http.method", ctx.Req.Method))
-			span.SetAttributes(attribute.String("peer.hostname", ctx.Req.Host))
+			span.SetAttributes(attribute.String("peer.hostname", ctx.Host()))
 			span.SetAttributes(attribute.String("http.url", ctx.Req.URL.String()))
-			span.SetAttributes(attribute.String("http.scheme", ctx.Req.URL.Scheme))
+			span.SetAttributes(attribute.String("http.scheme", ctx.Scheme()))
 			span.SetAttributes(attribute.String("span.kind", "server"))
 			span.SetAttributes(attribute.String("component", "web"))
 			span.SetAttributes(attribute.String("peer.address", ctx.Req.RemoteAddr))
+			span.SetAttributes(attribute.String("http.client_ip", ctx.ClientIP()))
 			span.SetAttributes(attribute.String("http.proto", ctx.Req.Proto))
 
 			// span.End 执行之后，就意味着 span 本身已经确定无疑了，将不能再变化了
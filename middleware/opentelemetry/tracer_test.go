@@ -2,7 +2,7 @@
 package opentelemetry
 
 import (
-	web "github.com/Ai-feier/geek-web/v9"
+	web "github.com/Ai-feier/geek-web"
 	"go.opentelemetry.io/otel"
 	"testing"
 	"time"
@@ -1,7 +1,6 @@
-//go:build v9
 package recovery
 
-import web "github.com/Ai-feier/geek-web/v9"
+import web "github.com/Ai-feier/geek-web"
 
 type MiddlewareBuilder struct {
 	StatusCode int
@@ -14,8 +13,16 @@ func (m *MiddlewareBuilder) Build() web.Middleware {
 		return func(ctx *web.Context) {
 			defer func() {
 				if err := recover(); err != nil {
-					ctx.RespStatusCode = m.StatusCode
-					ctx.RespData = []byte(m.ErrMsg)
+					// 客户端接受的时候，以 problem details 响应
+					if ctx.AcceptsProblem() {
+						_ = ctx.RespProblem(web.NewProblem(m.StatusCode, m.ErrMsg))
+					} else {
+						ctx.RespStatusCode = m.StatusCode
+						ctx.RespData = []byte(m.ErrMsg)
+					}
+					if m.LogFunc == nil {
+						return
+					}
 					// 万一 LogFunc 也panic，那我们也无能为力了
 					m.LogFunc(ctx)
 				}
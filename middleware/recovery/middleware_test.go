@@ -2,7 +2,7 @@
 package recovery
 
 import (
-	web "github.com/Ai-feier/geek-web/v9"
+	web "github.com/Ai-feier/geek-web"
 	"log"
 	"testing"
 )
@@ -0,0 +1,53 @@
+package recovery
+
+import (
+	web "github.com/Ai-feier/geek-web"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareBuilder_Problem(t *testing.T) {
+	builder := &MiddlewareBuilder{
+		StatusCode: http.StatusInternalServerError,
+		ErrMsg:     "成功从 panic 中恢复",
+	}
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.GET("/panic", func(ctx *web.Context) {
+		panic("测试 panic 恢复的 middleware")
+	})
+
+	testCases := []struct {
+		name     string
+		accept   string
+		wantType string
+		wantBody string
+	}{
+		{
+			name:     "plain",
+			wantBody: "成功从 panic 中恢复",
+		},
+		{
+			name:     "problem",
+			accept:   web.MIMEProblemJSON,
+			wantType: web.MIMEProblemJSON,
+			wantBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"成功从 panic 中恢复"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+			req.Header.Set("Accept", tc.accept)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			if tc.wantType != "" {
+				assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+				return
+			}
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrNotAcceptable 客户端能接受的类型，服务端都不能提供
+var ErrNotAcceptable = NewHTTPError(http.StatusNotAcceptable, "")
+
+// Offers 服务端能够提供的响应
+type Offers struct {
+	// Types 能够提供的 Content-Type，例如 application/json
+	// Accept 里面 q 值一样的时候，排在前面的优先
+	Types []string
+	// Data 响应数据
+	Data any
+	// TemplateName 返回 text/html 的时候，使用该模板渲染 Data
+	TemplateName string
+}
+
+// Negotiate 根据 Accept 从 offers.Types 中选择最合适的类型，并且编码 offers.Data
+// 没有 Accept 的时候使用 offers.Types 中的第一个，都不能接受的时候响应 406 并且返回 ErrNotAcceptable
+func (c *Context) Negotiate(code int, offers Offers) error {
+	c.Resp.Header().Add("Vary", "Accept")
+	typ, ok := c.NegotiateType(offers.Types...)
+	if !ok {
+		c.RespString(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+		return ErrNotAcceptable
+	}
+	switch typ {
+	case MIMEHTML:
+		if err := c.Render(offers.TemplateName, offers.Data); err != nil {
+			return err
+		}
+		c.RespStatusCode = code
+		return nil
+	case MIMEPlain:
+		c.RespString(code, fmt.Sprint(offers.Data))
+		return nil
+	default:
+		// 其它类型交给注册的 Codec
+		return c.RespEncoded(code, typ, offers.Data)
+	}
+}
+
+// NegotiateType 根据 Accept 从 offers 中选择最合适的类型
+func (c *Context) NegotiateType(offers ...string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	header := strings.Join(c.Req.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		return offers[0], true
+	}
+	accepts := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		// 严格大于，保证 q 值一样的时候前面的优先
+		if q := matchAccept(accepts, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// acceptRange Accept 中的一项，例如 text/*;q=0.8
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+func parseAccept(header string) []acceptRange {
+	parts := strings.Split(header, ",")
+	res := make([]acceptRange, 0, len(parts))
+	for _, part := range parts {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaRange)), "/")
+		if !ok || typ == "" || subtype == "" {
+			// 兼容一些客户端直接发送 * 的情况
+			if strings.TrimSpace(mediaRange) != "*" {
+				continue
+			}
+			typ, subtype = "*", "*"
+		}
+		ar := acceptRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range strings.Split(params, ";") {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(key) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(val, 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			ar.q = q
+		}
+		res = append(res, ar)
+	}
+	return res
+}
+
+// matchAccept 返回 offer 对应的 q 值
+// 多个范围都能匹配的时候，以最具体的为准，例如 text/html 优先于 text/*，text/* 优先于 */*
+func matchAccept(accepts []acceptRange, offer string) float64 {
+	mediaType, _, _ := strings.Cut(offer, ";")
+	typ, subtype, _ := strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+	q, specificity := 0.0, -1
+	for _, ar := range accepts {
+		var s int
+		switch {
+		case ar.typ == typ && ar.subtype == subtype:
+			s = 2
+		case ar.typ == typ && ar.subtype == "*":
+			s = 1
+		case ar.typ == "*" && ar.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContext_NegotiateType(t *testing.T) {
+	offers := []string{MIMEJSON, MIMEXML, MIMEHTML}
+	testCases := []struct {
+		name   string
+		accept string
+		want   string
+		wantOk bool
+	}{
+		{name: "no accept", want: MIMEJSON, wantOk: true},
+		{name: "exact", accept: "application/xml", want: MIMEXML, wantOk: true},
+		{name: "wildcard", accept: "*/*", want: MIMEJSON, wantOk: true},
+		{name: "sub wildcard", accept: "text/*", want: MIMEHTML, wantOk: true},
+		{
+			name:   "browser",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			want:   MIMEHTML,
+			wantOk: true,
+		},
+		{name: "q value", accept: "application/json;q=0.5, application/xml", want: MIMEXML, wantOk: true},
+		// 更具体的范围优先，所以 application/json 的 q 是 0
+		{name: "explicitly refused", accept: "application/json;q=0, */*;q=0.1", want: MIMEXML, wantOk: true},
+		{name: "same q", accept: "application/xml, application/json", want: MIMEJSON, wantOk: true},
+		{name: "case insensitive", accept: "Application/XML", want: MIMEXML, wantOk: true},
+		{name: "not acceptable", accept: "image/png"},
+		{name: "invalid q", accept: "application/json;q=abc"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			ctx := &Context{Req: req}
+			typ, ok := ctx.NegotiateType(offers...)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.want, typ)
+		})
+	}
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+	tpl, err := template.New("user").Parse(`<p>{{.Name}}</p>`)
+	require.NoError(t, err)
+	s := NewHTTPServer(ServerWithTemplateEngine(&GoTemplateEngine{T: tpl}))
+	var negotiateErr error
+	s.GET("/user", func(ctx *Context) {
+		negotiateErr = ctx.Negotiate(http.StatusOK, Offers{
+			Types:        []string{MIMEJSON, MIMEXML, MIMEHTML},
+			Data:         user{Name: "Tom"},
+			TemplateName: "user",
+		})
+	})
+
+	testCases := []struct {
+		name     string
+		accept   string
+		wantCode int
+		wantType string
+		wantBody string
+		wantErr  error
+	}{
+		{
+			name:     "json",
+			accept:   "application/json",
+			wantCode: http.StatusOK,
+			wantType: "application/json; charset=utf-8",
+			wantBody: `{"name":"Tom"}`,
+		},
+		{
+			name:     "xml",
+			accept:   "application/xml",
+			wantCode: http.StatusOK,
+			wantType: "application/xml; charset=utf-8",
+			wantBody: `<user><name>Tom</name></user>`,
+		},
+		{
+			name:     "html",
+			accept:   "text/html",
+			wantCode: http.StatusOK,
+			wantType: "text/html; charset=utf-8",
+			wantBody: `<p>Tom</p>`,
+		},
+		{
+			name:     "not acceptable",
+			accept:   "image/png",
+			wantCode: http.StatusNotAcceptable,
+			wantType: "text/plain; charset=utf-8",
+			wantBody: "Not Acceptable",
+			wantErr:  ErrNotAcceptable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Accept", tc.accept)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.True(t, errors.Is(negotiateErr, tc.wantErr))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
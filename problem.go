@@ -0,0 +1,144 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MIMEProblemJSON RFC 7807 定义的错误响应格式
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem RFC 7807 的 problem details
+// 可以作为 error 从 HandleFuncE 返回，也可以通过 RespProblem 直接响应
+type Problem struct {
+	// Type 指向错误说明文档的 URI，默认是 about:blank
+	Type string
+	// Title 简短的错误描述，同一个 Type 的 Title 应该是一样的
+	Title string
+	// Status 响应码
+	Status int
+	// Detail 这一次出错的具体原因
+	Detail string
+	// Instance 出错的具体资源，例如请求的路径
+	Instance string
+	// Extensions 其它的字段，和标准字段平铺在一起，例如 {"balance": 30}
+	Extensions map[string]any
+}
+
+// NewProblem Title 是 status 对应的默认描述
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// With 添加扩展字段，返回 p 本身，方便链式调用
+func (p *Problem) With(key string, val any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any, 2)
+	}
+	p.Extensions[key] = val
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("web: %d %s", p.Status, p.Title)
+	}
+	return fmt.Sprintf("web: %d %s: %s", p.Status, p.Title, p.Detail)
+}
+
+// MarshalJSON 扩展字段和标准字段平铺在一起，同名的时候以标准字段为准
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	res := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		res[k] = v
+	}
+	setNotEmpty := func(key string, val string) {
+		if val != "" {
+			res[key] = val
+		} else {
+			delete(res, key)
+		}
+	}
+	setNotEmpty("type", p.Type)
+	setNotEmpty("title", p.Title)
+	setNotEmpty("detail", p.Detail)
+	setNotEmpty("instance", p.Instance)
+	delete(res, "status")
+	if p.Status != 0 {
+		res["status"] = p.Status
+	}
+	return json.Marshal(res)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*p = Problem{}
+	members := map[string]any{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.Status,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+	}
+	for key, raw := range fields {
+		if ptr, ok := members[key]; ok {
+			if err := json.Unmarshal(raw, ptr); err != nil {
+				return fmt.Errorf("web: problem 字段 %s: %w", key, err)
+			}
+			continue
+		}
+		var val any
+		if err := json.Unmarshal(raw, &val); err != nil {
+			return err
+		}
+		p.With(key, val)
+	}
+	return nil
+}
+
+// RespProblem 以 application/problem+json 响应，Status 为 0 的时候按照 500 处理
+func (c *Context) RespProblem(p *Problem) error {
+	code := p.Status
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	return c.RespEncoded(code, MIMEProblemJSON, p)
+}
+
+// AcceptsProblem 客户端的 Accept 里面明确包含了 application/problem+json
+// 框架自己产生的错误，例如 404、405、绑定参数失败，只有这个时候才会以 problem details 响应，
+// 这样不会影响到没有适配的客户端
+func (c *Context) AcceptsProblem() bool {
+	for _, ar := range parseAccept(strings.Join(c.Req.Header.Values("Accept"), ",")) {
+		if ar.typ == "application" && ar.subtype == "problem+json" {
+			return ar.q > 0
+		}
+	}
+	return false
+}
+
+// respError 框架产生的错误响应，客户端接受的时候使用 problem details，否则是纯文本
+func (c *Context) respError(code int, msg string) {
+	if c.AcceptsProblem() {
+		detail := msg
+		if detail == http.StatusText(code) {
+			detail = ""
+		}
+		p := NewProblem(code, detail)
+		p.Instance = c.Req.URL.Path
+		_ = c.RespProblem(p)
+		return
+	}
+	c.RespStatusCode = code
+	c.RespData = []byte(msg)
+}
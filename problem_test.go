@@ -0,0 +1,161 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblem_JSON(t *testing.T) {
+	p := NewProblem(http.StatusForbidden, "余额不足").With("balance", 30).With("status", "ignored")
+	p.Type = "https://example.com/probs/out-of-credit"
+	p.Instance = "/account/12345"
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "https://example.com/probs/out-of-credit",
+		"title": "Forbidden",
+		"status": 403,
+		"detail": "余额不足",
+		"instance": "/account/12345",
+		"balance": 30
+	}`, string(data))
+
+	var res Problem
+	require.NoError(t, json.Unmarshal(data, &res))
+	assert.Equal(t, Problem{
+		Type:       "https://example.com/probs/out-of-credit",
+		Title:      "Forbidden",
+		Status:     http.StatusForbidden,
+		Detail:     "余额不足",
+		Instance:   "/account/12345",
+		Extensions: map[string]any{"balance": float64(30)},
+	}, res)
+	assert.Equal(t, "web: 403 Forbidden: 余额不足", p.Error())
+}
+
+func TestProblem_Server(t *testing.T) {
+	s := NewHTTPServer()
+	s.GET("/account", HandleE(func(ctx *Context) error {
+		return NewProblem(http.StatusForbidden, "余额不足").With("balance", 30)
+	}))
+	s.POST("/user", HandleE(func(ctx *Context) error {
+		var u struct {
+			Name string `json:"name" validate:"required"`
+		}
+		return ctx.Bind(&u)
+	}))
+	s.GET("/error", HandleE(func(ctx *Context) error {
+		return errors.New("db error")
+	}))
+
+	testCases := []struct {
+		name        string
+		method      string
+		path        string
+		accept      string
+		body        string
+		wantCode    int
+		wantType    string
+		wantBody    string
+		wantHeaders map[string]string
+	}{
+		{
+			// 业务代码返回的 Problem 不管 Accept 是什么
+			name:     "returned problem",
+			method:   http.MethodGet,
+			path:     "/account",
+			wantCode: http.StatusForbidden,
+			wantType: MIMEProblemJSON,
+			wantBody: `{"type":"about:blank","title":"Forbidden","status":403,"detail":"余额不足","balance":30}`,
+		},
+		{
+			name:     "not found",
+			method:   http.MethodGet,
+			path:     "/missing",
+			accept:   "application/problem+json, application/json;q=0.9",
+			wantCode: http.StatusNotFound,
+			wantType: MIMEProblemJSON,
+			wantBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"NOT FOUND","instance":"/missing"}`,
+		},
+		{
+			name:     "not found plain",
+			method:   http.MethodGet,
+			path:     "/missing",
+			accept:   "*/*",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:        "method not allowed",
+			method:      http.MethodPost,
+			path:        "/account",
+			accept:      MIMEProblemJSON,
+			wantCode:    http.StatusMethodNotAllowed,
+			wantType:    MIMEProblemJSON,
+			wantBody:    `{"type":"about:blank","title":"Method Not Allowed","status":405,"instance":"/account"}`,
+			wantHeaders: map[string]string{"Allow": "GET"},
+		},
+		{
+			name:        "method not allowed plain",
+			method:      http.MethodGet,
+			path:        "/user",
+			wantCode:    http.StatusMethodNotAllowed,
+			wantBody:    "Method Not Allowed",
+			wantHeaders: map[string]string{"Allow": "POST"},
+		},
+		{
+			name:     "bind error",
+			method:   http.MethodPost,
+			path:     "/user",
+			accept:   MIMEProblemJSON,
+			body:     `{"name":`,
+			wantCode: http.StatusBadRequest,
+			wantType: MIMEProblemJSON,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"instance":"/user"}`,
+		},
+		{
+			name:     "validation error",
+			method:   http.MethodPost,
+			path:     "/user",
+			accept:   MIMEProblemJSON,
+			body:     `{}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantType: MIMEProblemJSON,
+			wantBody: `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"参数校验失败","instance":"/user","fields":{"name":"不能为空"}}`,
+		},
+		{
+			name:     "internal error",
+			method:   http.MethodGet,
+			path:     "/error",
+			accept:   MIMEProblemJSON,
+			wantCode: http.StatusInternalServerError,
+			wantType: MIMEProblemJSON,
+			wantBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/error"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Accept", tc.accept)
+			req.Header.Set("Content-Type", MIMEJSON)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantType != "" {
+				assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+				assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+			} else {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+			for k, v := range tc.wantHeaders {
+				assert.Equal(t, v, recorder.Header().Get(k))
+			}
+		})
+	}
+}
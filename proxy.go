@@ -0,0 +1,195 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ServerWithTrustedProxies 设置受信任的代理，例如负载均衡器，可以是 CIDR 或者单个 IP，
+// 例如 ServerWithTrustedProxies("10.0.0.0/8", "127.0.0.1")
+// 只有请求直接来自于这些代理的时候，才会使用 Forwarded、X-Forwarded-For、X-Real-IP 之类的请求头，
+// 否则任何人都可以伪造这些请求头。不设置的时候不信任任何代理
+func ServerWithTrustedProxies(cidrs ...string) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.trustedProxies = make([]netip.Prefix, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			server.trustedProxies = append(server.trustedProxies, mustParsePrefix(cidr))
+		}
+	}
+}
+
+func mustParsePrefix(cidr string) netip.Prefix {
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("web: 非法的代理地址 %s: %v", cidr, err))
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen())
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		panic(fmt.Sprintf("web: 非法的代理地址 %s: %v", cidr, err))
+	}
+	if prefix.Addr().Is4In6() {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked()
+}
+
+// ClientIP 客户端的 IP
+// 请求来自受信任的代理时，依次使用 Forwarded、X-Forwarded-For、X-Real-IP，
+// 并且从右往左跳过受信任的代理，第一个不受信任的地址就是客户端，
+// 否则直接使用 Req.RemoteAddr
+func (c *Context) ClientIP() string {
+	remote, ok := parseIP(c.Req.RemoteAddr)
+	if !ok {
+		return c.Req.RemoteAddr
+	}
+	if !c.isTrustedProxy(remote) {
+		return remote.String()
+	}
+	var hops []string
+	if forwarded := c.Req.Header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, elem := range parseForwarded(forwarded) {
+			hops = append(hops, elem["for"])
+		}
+	} else if xff := c.Req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops = splitHeaderValues(xff)
+	} else if realIP := c.Req.Header.Get("X-Real-IP"); realIP != "" {
+		hops = []string{realIP}
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseIP(hops[i])
+		if !ok {
+			// 例如 for=unknown 或者 for=_hidden，没办法再往前追溯了
+			break
+		}
+		client = ip
+		if !c.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Scheme 客户端使用的协议，http 或者 https
+// 请求来自受信任的代理时，依次使用 Forwarded 的 proto 以及 X-Forwarded-Proto，取值规则和 Host 一样
+func (c *Context) Scheme() string {
+	if c.fromTrustedProxy() {
+		if proto := c.forwardedValue("proto", "X-Forwarded-Proto"); proto != "" {
+			return strings.ToLower(proto)
+		}
+	}
+	if c.Req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host 客户端请求的 Host
+// 请求来自受信任的代理时，依次使用 Forwarded 的 host 以及 X-Forwarded-Host，
+// 和 ClientIP 一样从右往左，只使用受信任的代理设置的值，客户端自己伪造的值会被忽略
+func (c *Context) Host() string {
+	if c.fromTrustedProxy() {
+		if host := c.forwardedValue("host", "X-Forwarded-Host"); host != "" {
+			return host
+		}
+	}
+	return c.Req.Host
+}
+
+// forwardedValue 受信任的代理设置的值里面，离客户端最近的那个
+// 最右边的值是直接和我们通信的代理设置的，
+// 往左的每一个值是否可信，取决于转发给右边那个代理的地址（也就是右边那个代理记录的 for）是否受信任
+func (c *Context) forwardedValue(param string, header string) string {
+	var vals, fors []string
+	if forwarded := c.Req.Header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, elem := range parseForwarded(forwarded) {
+			vals = append(vals, elem[param])
+			fors = append(fors, elem["for"])
+		}
+	} else {
+		vals = splitHeaderValues(c.Req.Header.Values(header))
+		// X-Forwarded-For 和 vals 右对齐，每个代理各自追加一个值
+		hops := splitHeaderValues(c.Req.Header.Values("X-Forwarded-For"))
+		fors = make([]string, len(vals))
+		for i := 1; i <= len(vals) && i <= len(hops); i++ {
+			fors[len(fors)-i] = hops[len(hops)-i]
+		}
+	}
+	var res string
+	for i := len(vals) - 1; i >= 0; i-- {
+		if vals[i] != "" {
+			res = vals[i]
+		}
+		ip, ok := parseIP(fors[i])
+		if !ok || !c.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return res
+}
+
+// splitHeaderValues 拆分多个请求头以及逗号分隔的值
+func splitHeaderValues(headers []string) []string {
+	var res []string
+	for _, header := range headers {
+		for _, val := range strings.Split(header, ",") {
+			res = append(res, strings.TrimSpace(val))
+		}
+	}
+	return res
+}
+
+func (c *Context) fromTrustedProxy() bool {
+	remote, ok := parseIP(c.Req.RemoteAddr)
+	return ok && c.isTrustedProxy(remote)
+}
+
+func (c *Context) isTrustedProxy(ip netip.Addr) bool {
+	for _, prefix := range c.trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP 兼容带端口以及 IPv6 带方括号的形式，例如 1.2.3.4:80、[::1]:80、[::1]
+func parseIP(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+// parseForwarded 解析 RFC 7239 的 Forwarded 请求头，
+// 例如 for=192.0.2.60;proto=http, for="[2001:db8::17]:4711"
+// 每个代理对应一个 map，key 统一转化为小写
+func parseForwarded(headers []string) []map[string]string {
+	var res []map[string]string
+	for _, header := range headers {
+		for _, elem := range strings.Split(header, ",") {
+			pairs := make(map[string]string, 4)
+			for _, pair := range strings.Split(elem, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				pairs[strings.ToLower(key)] = strings.Trim(val, `"`)
+			}
+			res = append(res, pairs)
+		}
+	}
+	return res
+}
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestContext_ClientIP(t *testing.T) {
+	trusted := []netip.Prefix{mustParsePrefix("10.0.0.0/8"), mustParsePrefix("2001:db8::1")}
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		wantIP     string
+	}{
+		{
+			name:       "no proxy",
+			remoteAddr: "203.0.113.7:1234",
+			wantIP:     "203.0.113.7",
+		},
+		{
+			// 不是受信任的代理，请求头可能是伪造的
+			name:       "untrusted proxy",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1"},
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "x-forwarded-for",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.0.0.2"},
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "x-forwarded-for all trusted",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			wantIP:     "10.0.0.3",
+		},
+		{
+			name:       "x-real-ip",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.7"},
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "forwarded",
+			remoteAddr: "[2001:db8::1]:1234",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`,
+				"X-Forwarded-For": "1.1.1.1",
+			},
+			wantIP: "2001:db8:cafe::17",
+		},
+		{
+			name:       "forwarded unknown",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": "for=unknown, for=10.0.0.2"},
+			wantIP:     "10.0.0.2",
+		},
+		{
+			name:       "ipv4 mapped",
+			remoteAddr: "[::ffff:10.0.0.1]:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			wantIP:     "203.0.113.7",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			ctx := &Context{Req: req, trustedProxies: trusted}
+			assert.Equal(t, tc.wantIP, ctx.ClientIP())
+		})
+	}
+}
+
+func TestContext_SchemeAndHost(t *testing.T) {
+	trusted := []netip.Prefix{mustParsePrefix("10.0.0.0/8")}
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		headers    map[string]string
+		wantScheme string
+		wantHost   string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"},
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "direct tls",
+			remoteAddr: "203.0.113.7:1234",
+			tls:        true,
+			wantScheme: "https",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "x-forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "HTTPS",
+				"X-Forwarded-Host":  "api.example.com, lb.internal",
+				"X-Forwarded-For":   "203.0.113.7, 10.0.0.2",
+			},
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		{
+			// 没有 X-Forwarded-For，没办法知道左边的值是谁设置的，只能使用直接通信的代理设置的值
+			name:       "x-forwarded without for",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-Host": "api.example.com, lb.internal"},
+			wantScheme: "http",
+			wantHost:   "lb.internal",
+		},
+		{
+			// 客户端自己带上了 X-Forwarded-Host，代理在后面追加
+			name:       "x-forwarded host spoofing",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-Host": "evil.com, api.example.com",
+				"X-Forwarded-For":  "203.0.113.7",
+			},
+			wantScheme: "http",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "x-forwarded proto spoofing",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "https, http",
+				"X-Forwarded-For":   "203.0.113.7",
+			},
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "forwarded host spoofing",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": `host=evil.com, for=203.0.113.7;host=api.example.com`},
+			wantScheme: "http",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "forwarded proto spoofing",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": `proto=https;host=evil.com, for=203.0.113.7;proto=http`},
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": `for=203.0.113.7;proto=https;host="api.example.com", for=10.0.0.2;proto=http`},
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			ctx := &Context{Req: req, trustedProxies: trusted}
+			assert.Equal(t, tc.wantScheme, ctx.Scheme())
+			assert.Equal(t, tc.wantHost, ctx.Host())
+		})
+	}
+}
+
+func TestServerWithTrustedProxies(t *testing.T) {
+	assert.Panics(t, func() {
+		NewHTTPServer(ServerWithTrustedProxies("10.0.0.0/33"))
+	})
+	s := NewHTTPServer(ServerWithTrustedProxies("10.0.0.1"))
+	s.GET("/", func(ctx *Context) {
+		ctx.RespString(http.StatusOK, ctx.ClientIP())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, "203.0.113.7", recorder.Body.String())
+}
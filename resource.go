@@ -0,0 +1,160 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 资源控制器可以实现的动作，只需要实现其中的一部分，例如只读的资源只需要实现 Indexer 和 Shower
+// 返回的 error 和 HandleE 一样交给 ErrorHandler
+
+// Indexer GET /users
+type Indexer interface {
+	Index(ctx *Context) error
+}
+
+// Shower GET /users/:id
+type Shower interface {
+	Show(ctx *Context) error
+}
+
+// Creator POST /users
+type Creator interface {
+	Create(ctx *Context) error
+}
+
+// Updater PUT /users/:id
+type Updater interface {
+	Update(ctx *Context) error
+}
+
+// Patcher PATCH /users/:id
+type Patcher interface {
+	Patch(ctx *Context) error
+}
+
+// Destroyer DELETE /users/:id
+type Destroyer interface {
+	Destroy(ctx *Context) error
+}
+
+// Resource 注册好的资源，可以继续注册嵌套的资源
+type Resource struct {
+	r Routable
+	// path 相对于 r 的路径，例如 /users
+	path string
+	// param 单个资源的路径参数名，例如 id
+	param string
+	// name 路由名字的前缀，例如 users
+	name string
+}
+
+type ResourceOption func(res *Resource)
+
+// ResourceWithParam 单个资源的路径参数名，
+// 顶层资源默认是 id，嵌套资源默认是路径的最后一段加上 _id，例如 /posts 是 posts_id
+func ResourceWithParam(param string) ResourceOption {
+	return func(res *Resource) {
+		res.param = param
+	}
+}
+
+// ResourceWithName 路由名字的前缀，默认是路径里面的静态部分用 . 连接，例如 /admin/users 是 admin.users，
+// 嵌套资源会加上父资源的前缀，例如 users.posts
+func ResourceWithName(name string) ResourceOption {
+	return func(res *Resource) {
+		res.name = name
+	}
+}
+
+// Resource 按照 REST 的约定注册 controller 实现了的动作，例如 server.Resource("/users", userController)
+//
+//	Index   GET    /users         users.index
+//	Create  POST   /users         users.create
+//	Show    GET    /users/:id     users.show
+//	Update  PUT    /users/:id     users.update
+//	Patch   PATCH  /users/:id     users.patch
+//	Destroy DELETE /users/:id     users.destroy
+//
+// 最后一列是路由的名字，可以通过 URL 生成路径
+// 没有实现的动作不会注册路由，同一个路径上有其它动作的时候，请求它会得到 405，
+// 否则是 404，例如只实现了 Index 的时候，POST /users 是 405，PUT /users/:id 是 404
+// controller 一个动作都没有实现的时候会 panic
+func (h *HTTPServer) Resource(path string, controller any, opts ...ResourceOption) *Resource {
+	return newResource(h, path, "id", resourceName(path, ""), controller, opts)
+}
+
+// Resource 在分组里面注册资源，路由名字包含分组的前缀，例如 /v1 分组里面的 /users 是 v1.users
+func (g *RouteGroup) Resource(path string, controller any, opts ...ResourceOption) *Resource {
+	return newResource(g, path, "id", resourceName(g.prefix+path, ""), controller, opts)
+}
+
+// Resource 注册嵌套资源，例如
+//
+//	users := server.Resource("/users", userController)
+//	users.Resource("/posts", postController) // GET /users/:id/posts/:posts_id users.posts.show
+func (res *Resource) Resource(path string, controller any, opts ...ResourceOption) *Resource {
+	seg := path[strings.LastIndexByte(path, '/')+1:]
+	return newResource(res.r, res.path+"/:"+res.param+path, seg+"_id", resourceName(path, res.name), controller, opts)
+}
+
+// Path 资源的路径，例如 /users/:id/posts
+func (res *Resource) Path() string {
+	return res.path
+}
+
+func newResource(r Routable, path string, param string, name string, controller any, opts []ResourceOption) *Resource {
+	res := &Resource{
+		r:     r,
+		path:  path,
+		param: param,
+		name:  name,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	item := res.path + "/:" + res.param
+	registered := false
+	register := func(method string, path string, action string, fn HandleFuncE) {
+		r.Handle(method, path, HandleE(fn), RouteWithName(res.name+"."+action))
+		registered = true
+	}
+	if c, ok := controller.(Indexer); ok {
+		register(http.MethodGet, res.path, "index", c.Index)
+	}
+	if c, ok := controller.(Creator); ok {
+		register(http.MethodPost, res.path, "create", c.Create)
+	}
+	if c, ok := controller.(Shower); ok {
+		register(http.MethodGet, item, "show", c.Show)
+	}
+	if c, ok := controller.(Updater); ok {
+		register(http.MethodPut, item, "update", c.Update)
+	}
+	if c, ok := controller.(Patcher); ok {
+		register(http.MethodPatch, item, "patch", c.Patch)
+	}
+	if c, ok := controller.(Destroyer); ok {
+		register(http.MethodDelete, item, "destroy", c.Destroy)
+	}
+	if !registered {
+		panic(fmt.Sprintf("web: 资源 %s 的 controller %T 没有实现任何动作", path, controller))
+	}
+	return res
+}
+
+// resourceName 路径里面的静态部分用 . 连接，并且加上父资源的前缀，例如 /admin/users 是 admin.users
+func resourceName(path string, parentName string) string {
+	var segs []string
+	if parentName != "" {
+		segs = append(segs, parentName)
+	}
+	for _, s := range strings.Split(strings.Trim(path, "/"), "/") {
+		if s == "" || s[0] == ':' || s == "*" {
+			continue
+		}
+		segs = append(segs, s)
+	}
+	return strings.Join(segs, ".")
+}
@@ -0,0 +1,253 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// userController 实现了所有的动作
+type userController struct{}
+
+func (userController) Index(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "index")
+	return nil
+}
+
+func (userController) Show(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "show "+ctx.PathParams["id"])
+	return nil
+}
+
+func (userController) Create(ctx *Context) error {
+	ctx.RespString(http.StatusCreated, "create")
+	return nil
+}
+
+func (userController) Update(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "update "+ctx.PathParams["id"])
+	return nil
+}
+
+func (userController) Patch(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "patch "+ctx.PathParams["id"])
+	return nil
+}
+
+func (userController) Destroy(ctx *Context) error {
+	ctx.NoContent()
+	return nil
+}
+
+// postController 只读
+type postController struct{}
+
+func (postController) Index(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "posts of "+ctx.PathParams["id"])
+	return nil
+}
+
+func (postController) Show(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "post "+ctx.PathParams["posts_id"]+" of "+ctx.PathParams["id"])
+	return nil
+}
+
+// commentController 只能创建，返回 error
+type commentController struct{}
+
+func (commentController) Create(ctx *Context) error {
+	return NewHTTPError(http.StatusForbidden, "禁止评论")
+}
+
+// tagController 只有列表
+type tagController struct{}
+
+func (tagController) Index(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "tags")
+	return nil
+}
+
+// profileController 只有详情
+type profileController struct{}
+
+func (profileController) Show(ctx *Context) error {
+	ctx.RespString(http.StatusOK, "profile "+ctx.PathParams["id"])
+	return nil
+}
+
+func TestHTTPServer_Resource(t *testing.T) {
+	s := NewHTTPServer()
+	users := s.Resource("/users", userController{})
+	posts := users.Resource("/posts", postController{})
+	// 不会去猜单数形式，/status 不会变成 statu_id
+	users.Resource("/status", profileController{})
+	posts.Resource("/comments", commentController{}, ResourceWithParam("cid"), ResourceWithName("comments"))
+	s.Group("/admin").Resource("/articles", postController{}, ResourceWithParam("aid"))
+	s.Resource("/tags", tagController{})
+	s.Resource("/profiles", profileController{})
+	// 不同分组里面的同名资源，路由名字不会冲突
+	s.Group("/v1").Resource("/users", userController{})
+	s.Group("/v2").Resource("/users", userController{})
+
+	testCases := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantBody  string
+		wantAllow string
+	}{
+		{name: "index", method: http.MethodGet, path: "/users", wantCode: http.StatusOK, wantBody: "index"},
+		{name: "create", method: http.MethodPost, path: "/users", wantCode: http.StatusCreated, wantBody: "create"},
+		{name: "show", method: http.MethodGet, path: "/users/123", wantCode: http.StatusOK, wantBody: "show 123"},
+		{name: "update", method: http.MethodPut, path: "/users/123", wantCode: http.StatusOK, wantBody: "update 123"},
+		{name: "patch", method: http.MethodPatch, path: "/users/123", wantCode: http.StatusOK, wantBody: "patch 123"},
+		{name: "destroy", method: http.MethodDelete, path: "/users/123", wantCode: http.StatusNoContent},
+		{name: "nested index", method: http.MethodGet, path: "/users/123/posts", wantCode: http.StatusOK, wantBody: "posts of 123"},
+		{name: "nested show", method: http.MethodGet, path: "/users/123/posts/456", wantCode: http.StatusOK, wantBody: "post 456 of 123"},
+		{
+			name:      "nested not implemented",
+			method:    http.MethodDelete,
+			path:      "/users/123/posts/456",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantBody:  "Method Not Allowed",
+			wantAllow: "GET",
+		},
+		{
+			name:      "collection not implemented",
+			method:    http.MethodPost,
+			path:      "/users/123/posts",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantBody:  "Method Not Allowed",
+			wantAllow: "GET",
+		},
+		{
+			name:     "deep nested error",
+			method:   http.MethodPost,
+			path:     "/users/123/posts/456/comments",
+			wantCode: http.StatusForbidden,
+			wantBody: "禁止评论",
+		},
+		{
+			name:      "deep nested not implemented",
+			method:    http.MethodGet,
+			path:      "/users/123/posts/456/comments",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantBody:  "Method Not Allowed",
+			wantAllow: "POST",
+		},
+		{name: "group", method: http.MethodGet, path: "/admin/articles", wantCode: http.StatusOK, wantBody: "posts of "},
+		{
+			// 路径上一个动作都没有实现
+			name:     "index only item",
+			method:   http.MethodPut,
+			path:     "/tags/1",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:      "index only options",
+			method:    http.MethodOptions,
+			path:      "/tags",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantBody:  "Method Not Allowed",
+			wantAllow: "GET",
+		},
+		{
+			name:     "index only item options",
+			method:   http.MethodOptions,
+			path:     "/tags/1",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:      "index only collection",
+			method:    http.MethodPost,
+			path:      "/tags",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantBody:  "Method Not Allowed",
+			wantAllow: "GET",
+		},
+		{
+			name:     "show only index",
+			method:   http.MethodGet,
+			path:     "/profiles",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:     "show only create",
+			method:   http.MethodPost,
+			path:     "/profiles",
+			wantCode: http.StatusNotFound,
+			wantBody: "NOT FOUND",
+		},
+		{
+			name:      "show only update",
+			method:    http.MethodPut,
+			path:      "/profiles/1",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantBody:  "Method Not Allowed",
+			wantAllow: "GET",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantAllow, recorder.Header().Get("Allow"))
+		})
+	}
+
+	assert.Equal(t, "/users/:id/posts", posts.Path())
+	params := map[string]string{"id": "1 2", "posts_id": "456", "status_id": "ok", "cid": "789", "aid": "9"}
+	urlTestCases := []struct {
+		name    string
+		route   string
+		want    string
+		wantErr string
+	}{
+		{name: "index", route: "users.index", want: "/users"},
+		{name: "destroy", route: "users.destroy", want: "/users/1%202"},
+		{name: "nested", route: "users.posts.show", want: "/users/1%202/posts/456"},
+		{name: "param not singularized", route: "users.status.show", want: "/users/1%202/status/ok"},
+		{name: "custom name", route: "comments.create", want: "/users/1%202/posts/456/comments"},
+		{name: "group", route: "admin.articles.show", want: "/admin/articles/9"},
+		{name: "group v1", route: "v1.users.show", want: "/v1/users/1%202"},
+		{name: "group v2", route: "v2.users.index", want: "/v2/users"},
+		{name: "unknown", route: "users.posts.destroy", wantErr: "web: 路由 users.posts.destroy 不存在"},
+	}
+	for _, tc := range urlTestCases {
+		t.Run("url "+tc.name, func(t *testing.T) {
+			res, err := s.URL(tc.route, params)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, res)
+		})
+	}
+	_, err := s.URL("users.show", nil)
+	assert.EqualError(t, err, "web: 生成路由 users.show 缺少参数 id")
+
+	// 没有实现的动作不占用路由，也不会出现在 Routes 里面
+	for _, route := range s.Routes() {
+		assert.NotEqual(t, "/tags/:id", route.Path)
+	}
+	assert.NotPanics(t, func() {
+		s.GET("/tags/:slug", func(ctx *Context) {})
+	})
+
+	assert.Panics(t, func() {
+		s.Resource("/empty", struct{}{})
+	})
+	assert.Panics(t, func() {
+		// 路由名字冲突
+		s.GET("/index", func(ctx *Context) {}, RouteWithName("users.index"))
+	})
+}
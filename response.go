@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+)
+
+// 下面这些方法都只是设置 RespStatusCode、RespData 以及响应头，
+// 真正的回写发生在所有 middleware 执行完毕之后，所以 middleware 依旧可以检查和修改响应
+
+const charsetUTF8 = "; charset=utf-8"
+
+func (c *Context) RespOK(val any) error {
+	return c.RespJSON(http.StatusOK, val)
+}
+
+func (c *Context) RespJSON(code int, val any) error {
+	return c.RespEncoded(code, MIMEJSON, val)
+}
+
+func (c *Context) RespXML(code int, val any) error {
+	return c.RespEncoded(code, MIMEXML, val)
+}
+
+// RespProtobuf 返回 application/x-protobuf
+func (c *Context) RespProtobuf(code int, msg proto.Message) error {
+	return c.RespEncoded(code, MIMEProtobuf, msg)
+}
+
+// RespMsgpack 返回 application/msgpack
+func (c *Context) RespMsgpack(code int, val any) error {
+	return c.RespEncoded(code, MIMEMsgpack, val)
+}
+
+func (c *Context) RespString(code int, val string) {
+	c.RespBytes(code, MIMEPlain+charsetUTF8, []byte(val))
+}
+
+// RespHTML 直接返回 HTML，如果需要使用模板，请使用 Render
+func (c *Context) RespHTML(code int, html string) {
+	c.RespBytes(code, MIMEHTML+charsetUTF8, []byte(html))
+}
+
+// RespBytes 返回任意类型的数据，contentType 为空的时候不设置 Content-Type
+func (c *Context) RespBytes(code int, contentType string, data []byte) {
+	if contentType != "" {
+		c.setContentType(contentType)
+	}
+	c.RespStatusCode = code
+	c.RespData = data
+}
+
+// Redirect 重定向，code 只能是 300、301、302、303、307、308，例如 http.StatusFound
+// 304、305 和 306 虽然也是 3xx，但是并不是重定向
+func (c *Context) Redirect(code int, location string) error {
+	switch code {
+	case http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusFound,
+		http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("web: 非法的重定向响应码 %d", code)
+	}
+	c.Resp.Header().Set("Location", location)
+	c.RespStatusCode = code
+	c.RespData = nil
+	return nil
+}
+
+// NoContent 返回 204，并且没有响应体
+func (c *Context) NoContent() {
+	c.RespStatusCode = http.StatusNoContent
+	c.RespData = nil
+}
+
+func (c *Context) setContentType(contentType string) {
+	c.Resp.Header().Set("Content-Type", contentType)
+}
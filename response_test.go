@@ -0,0 +1,121 @@
+package web
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContext_Resp(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+	testCases := []struct {
+		name       string
+		handler    HandleFunc
+		wantCode   int
+		wantType   string
+		wantBody   string
+		wantHeader http.Header
+	}{
+		{
+			name: "json",
+			handler: func(ctx *Context) {
+				_ = ctx.RespJSON(http.StatusCreated, user{Name: "Tom"})
+			},
+			wantCode: http.StatusCreated,
+			wantType: "application/json; charset=utf-8",
+			wantBody: `{"name":"Tom"}`,
+		},
+		{
+			name: "xml",
+			handler: func(ctx *Context) {
+				_ = ctx.RespXML(http.StatusOK, user{Name: "Tom"})
+			},
+			wantCode: http.StatusOK,
+			wantType: "application/xml; charset=utf-8",
+			wantBody: `<user><name>Tom</name></user>`,
+		},
+		{
+			name: "string",
+			handler: func(ctx *Context) {
+				ctx.RespString(http.StatusOK, "hello")
+			},
+			wantCode: http.StatusOK,
+			wantType: "text/plain; charset=utf-8",
+			wantBody: "hello",
+		},
+		{
+			name: "html",
+			handler: func(ctx *Context) {
+				ctx.RespHTML(http.StatusOK, "<h1>hello</h1>")
+			},
+			wantCode: http.StatusOK,
+			wantType: "text/html; charset=utf-8",
+			wantBody: "<h1>hello</h1>",
+		},
+		{
+			name: "bytes",
+			handler: func(ctx *Context) {
+				ctx.RespBytes(http.StatusOK, "image/png", []byte{1, 2, 3})
+			},
+			wantCode: http.StatusOK,
+			wantType: "image/png",
+			wantBody: string([]byte{1, 2, 3}),
+		},
+		{
+			name: "redirect",
+			handler: func(ctx *Context) {
+				assert.NoError(t, ctx.Redirect(http.StatusFound, "/login"))
+			},
+			wantCode:   http.StatusFound,
+			wantHeader: http.Header{"Location": {"/login"}},
+		},
+		{
+			name: "invalid redirect",
+			handler: func(ctx *Context) {
+				assert.EqualError(t, ctx.Redirect(http.StatusOK, "/login"), "web: 非法的重定向响应码 200")
+				for _, code := range []int{http.StatusNotModified, http.StatusUseProxy, 306} {
+					assert.EqualError(t, ctx.Redirect(code, "/login"), fmt.Sprintf("web: 非法的重定向响应码 %d", code))
+				}
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "no content",
+			handler: func(ctx *Context) {
+				ctx.NoContent()
+			},
+			wantCode: http.StatusNoContent,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mdlCode int
+			var mdlData []byte
+			s := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+				return func(ctx *Context) {
+					next(ctx)
+					// 响应还没有发送，middleware 能够看到
+					assert.False(t, ctx.Resp.Written())
+					mdlCode, mdlData = ctx.RespStatusCode, ctx.RespData
+				}
+			}))
+			s.GET("/", tc.handler)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantBody, string(mdlData))
+			if tc.wantCode != http.StatusOK || mdlCode != 0 {
+				assert.Equal(t, tc.wantCode, mdlCode)
+			}
+			for k := range tc.wantHeader {
+				assert.Equal(t, tc.wantHeader.Get(k), recorder.Header().Get(k))
+			}
+		})
+	}
+}
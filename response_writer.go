@@ -0,0 +1,102 @@
+package web
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter 在 http.ResponseWriter 的基础上，
+// 记录了响应码、写入的字节数以及响应是否已经提交（响应头已经发送）
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status 返回已经发送的响应码，没有发送的时候返回 0
+	Status() int
+	// Size 返回已经写入的响应体的字节数
+	Size() int
+	// Written 响应头是否已经发送。一旦发送，就不能再修改响应码和响应头了
+	Written() bool
+}
+
+var _ ResponseWriter = &responseWriter{}
+var _ http.Flusher = &responseWriter{}
+var _ http.Hijacker = &responseWriter{}
+var _ http.Pusher = &responseWriter{}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = 0
+	w.size = 0
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// 重复调用 WriteHeader 只会让 net/http 打印 superfluous 的日志，这里直接忽略
+	if w.Written() {
+		return
+	}
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
+
+// Flush 如果底层的 http.ResponseWriter 不支持 Flush，那么什么也不做
+func (w *responseWriter) Flush() {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && !w.Written() {
+		// 连接已经交给调用者了，后面不能再通过 http 的方式回写响应
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// Unwrap 让 http.ResponseController 能够拿到原生的 http.ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
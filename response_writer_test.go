@@ -0,0 +1,96 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	var status, size int
+	s := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			status = ctx.RespStatusCode
+			size = ctx.Resp.Size()
+		}
+	}))
+	s.GET("/direct", func(ctx *Context) {
+		ctx.Resp.WriteHeader(http.StatusAccepted)
+		_, _ = ctx.Resp.Write([]byte("direct"))
+		// 已经提交的响应，这些都不会生效
+		ctx.Resp.WriteHeader(http.StatusInternalServerError)
+		ctx.RespData = []byte("buffered")
+	})
+	s.GET("/buffered", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusCreated
+		ctx.RespData = []byte("buffered")
+	})
+	s.GET("/flush", func(ctx *Context) {
+		_, _ = ctx.Resp.Write([]byte("part"))
+		assert.NoError(t, http.NewResponseController(ctx.Resp).Flush())
+	})
+
+	testCases := []struct {
+		name       string
+		path       string
+		wantCode   int
+		wantBody   string
+		wantStatus int
+		wantSize   int
+		wantFlush  bool
+	}{
+		{
+			name:       "direct",
+			path:       "/direct",
+			wantCode:   http.StatusAccepted,
+			wantBody:   "direct",
+			wantStatus: http.StatusAccepted,
+			wantSize:   6,
+		},
+		{
+			name:       "buffered",
+			path:       "/buffered",
+			wantCode:   http.StatusCreated,
+			wantBody:   "buffered",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "not found",
+			path:       "/abc",
+			wantCode:   http.StatusNotFound,
+			wantBody:   "NOT FOUND",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "flush",
+			path:       "/flush",
+			wantCode:   http.StatusOK,
+			wantBody:   "part",
+			wantStatus: http.StatusOK,
+			wantSize:   4,
+			wantFlush:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantStatus, status)
+			assert.Equal(t, tc.wantSize, size)
+			assert.Equal(t, tc.wantFlush, recorder.Flushed)
+		})
+	}
+}
+
+func TestResponseWriter_Hijack(t *testing.T) {
+	// httptest.ResponseRecorder 不支持 Hijack
+	w := &responseWriter{ResponseWriter: httptest.NewRecorder()}
+	_, _, err := w.Hijack()
+	assert.Equal(t, http.ErrNotSupported, err)
+	assert.Equal(t, http.ErrNotSupported, w.Push("/abc.js", nil))
+	assert.False(t, w.Written())
+}
@@ -0,0 +1,163 @@
+//go:build !windows
+
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// envListenerFd 父进程通过该环境变量告诉子进程继承的 socket 的文件描述符
+	envListenerFd = "WEB_LISTENER_FD"
+	// envReadyFd 子进程准备好之后，往这个文件描述符写入一个字节通知父进程
+	envReadyFd = "WEB_READY_FD"
+)
+
+// inheritedListener 尝试使用父进程传递过来的 socket
+// 第二个返回值代表当前进程是否是热重启拉起来的子进程
+// 只会被使用一次，同一个进程里面的其它服务器会监听自己的地址
+func inheritedListener() (net.Listener, bool, error) {
+	val := os.Getenv(envListenerFd)
+	if val == "" {
+		return nil, false, nil
+	}
+	_ = os.Unsetenv(envListenerFd)
+	fd, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, true, fmt.Errorf("web: 非法的文件描述符 %s", val)
+	}
+	f := os.NewFile(uintptr(fd), "listener")
+	// FileListener 内部会 dup 一份文件描述符，所以这里可以关掉
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	return ln, true, err
+}
+
+// notifyReady 如果当前进程是热重启拉起来的子进程，那么通知父进程可以开始退出了
+// 只会通知一次
+func notifyReady() {
+	val := os.Getenv(envReadyFd)
+	if val == "" {
+		return
+	}
+	_ = os.Unsetenv(envReadyFd)
+	fd, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("web: 非法的文件描述符 %s", val)
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	if _, err = f.Write([]byte{1}); err != nil {
+		log.Println("web: 通知父进程失败", err)
+	}
+}
+
+// watchRestart 监听 SIGUSR2 信号，收到之后拉起子进程并优雅退出
+func (h *HTTPServer) watchRestart(ln net.Listener) {
+	ch := make(chan os.Signal, 1)
+	// 一直不调用 signal.Stop，否则在重试或者退出的过程中，
+	// 再收到 SIGUSR2 会执行默认的动作，直接杀死当前进程
+	signal.Notify(ch, syscall.SIGUSR2)
+	h.restartLoop(ln, ch)
+}
+
+func (h *HTTPServer) restartLoop(ln net.Listener, ch <-chan os.Signal) {
+	for range ch {
+		if err := h.fork(ln); err != nil {
+			// 子进程没起来，那么当前进程继续提供服务，等待下一次信号
+			log.Println("web: 热重启失败", err)
+			continue
+		}
+		break
+	}
+	h.restarting.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), h.shutdownTimeout)
+	defer cancel()
+	h.drained <- h.Shutdown(ctx)
+}
+
+// fork 启动一个新的进程，并将 socket 作为第一个额外的文件传递过去，
+// 第二个额外的文件是通知父进程已经准备好的管道。
+// 只有子进程开始提供服务之后才会返回 nil，子进程启动失败或者超时都会返回 error，并且杀死子进程
+func (h *HTTPServer) fork(ln net.Listener) error {
+	fl, ok := ln.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return fmt.Errorf("web: 不支持热重启的 listener %T", ln)
+	}
+	f, err := fl.File()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// ExtraFiles 中的第 i 个文件在子进程中的文件描述符是 3+i
+	cmd.ExtraFiles = []*os.File{f, readyW}
+	cmd.Env = append(childEnv(), envListenerFd+"=3", envReadyFd+"=4")
+	err = cmd.Start()
+	// 父进程自己的写端要关掉，这样子进程退出的时候读端才能读到 EOF
+	_ = readyW.Close()
+	if err != nil {
+		return err
+	}
+	if err = waitReady(readyR, h.readyTimeout); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	// 回收子进程，避免当前进程还没有退出的时候子进程变成僵尸进程
+	go func() {
+		_ = cmd.Wait()
+	}()
+	return nil
+}
+
+// waitReady 等待子进程写入一个字节，子进程退出的时候会读到 EOF
+func waitReady(r *os.File, timeout time.Duration) error {
+	if err := r.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	var buf [1]byte
+	if _, err := r.Read(buf[:]); err != nil {
+		return fmt.Errorf("web: 等待子进程就绪失败: %w", err)
+	}
+	return nil
+}
+
+// childEnv 去除已有的 envListenerFd 和 envReadyFd，避免多次热重启之后重复
+func childEnv() []string {
+	env := os.Environ()
+	res := make([]string, 0, len(env)+2)
+	for _, e := range env {
+		if !strings.HasPrefix(e, envListenerFd+"=") && !strings.HasPrefix(e, envReadyFd+"=") {
+			res = append(res, e)
+		}
+	}
+	return res
+}
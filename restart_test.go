@@ -0,0 +1,187 @@
+//go:build !windows
+
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 子进程用到的环境变量
+const (
+	envRestartChild  = "WEB_TEST_RESTART_CHILD"
+	envRestartMarker = "WEB_TEST_RESTART_MARKER"
+)
+
+func TestInheritedListener(t *testing.T) {
+	t.Setenv(envListenerFd, "")
+	ln, ok, err := inheritedListener()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, ln)
+
+	t.Setenv(envListenerFd, "abc")
+	_, ok, err = inheritedListener()
+	assert.True(t, ok)
+	assert.Error(t, err)
+
+	origin, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer origin.Close()
+	f, err := origin.(*net.TCPListener).File()
+	require.NoError(t, err)
+	// inheritedListener 会关闭传进去的文件描述符，所以复制一份，避免被 f 重复关闭
+	fd, err := syscall.Dup(int(f.Fd()))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	t.Setenv(envListenerFd, strconv.Itoa(fd))
+	ln, ok, err = inheritedListener()
+	require.NoError(t, err)
+	require.True(t, ok)
+	defer ln.Close()
+	assert.Equal(t, origin.Addr().String(), ln.Addr().String())
+	// 只能被使用一次
+	_, ok, err = inheritedListener()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	go func() {
+		conn, err := net.Dial("tcp", origin.Addr().String())
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	_ = conn.Close()
+}
+
+func TestChildEnv(t *testing.T) {
+	t.Setenv(envListenerFd, "3")
+	t.Setenv(envReadyFd, "4")
+	t.Setenv("WEB_TEST_KEEP", "1")
+	env := childEnv()
+	assert.Contains(t, env, "WEB_TEST_KEEP=1")
+	for _, e := range env {
+		assert.NotContains(t, e, envListenerFd+"=")
+		assert.NotContains(t, e, envReadyFd+"=")
+	}
+}
+
+// TestRestartChild 被 TestHotRestart 作为子进程拉起来，直接运行的时候跳过
+func TestRestartChild(t *testing.T) {
+	if os.Getenv(envRestartChild) == "" {
+		t.Skip("只在热重启测试的子进程中运行")
+	}
+	// 标记文件存在的时候，模拟子进程启动失败
+	if marker := os.Getenv(envRestartMarker); marker != "" {
+		if _, err := os.Stat(marker); err == nil {
+			_ = os.Remove(marker)
+			os.Exit(1)
+		}
+	}
+	// 无论如何都不要一直留在后台
+	time.AfterFunc(20*time.Second, func() {
+		os.Exit(0)
+	})
+	// 没有开启热重启的服务器不会使用父进程传递过来的 socket
+	other := NewHTTPServer()
+	otherLn, err := other.listen("127.0.0.1:0")
+	if err != nil {
+		os.Exit(2)
+	}
+	_ = otherLn.Close()
+	s := NewHTTPServer(ServerWithHotRestart(time.Second))
+	s.GET("/", func(ctx *Context) {
+		ctx.RespString(http.StatusOK, "child")
+	})
+	s.GET("/quit", func(ctx *Context) {
+		time.AfterFunc(10*time.Millisecond, func() {
+			os.Exit(0)
+		})
+	})
+	_ = s.Start("")
+	os.Exit(0)
+}
+
+func TestHotRestart(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "crash")
+	require.NoError(t, os.WriteFile(marker, nil, 0o600))
+	t.Setenv(envRestartChild, "1")
+	t.Setenv(envRestartMarker, marker)
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestRestartChild$"}
+	defer func() {
+		os.Args = args
+	}()
+
+	s := NewHTTPServer(ServerWithRestartReadyTimeout(10 * time.Second))
+	slowStarted := make(chan struct{})
+	s.GET("/", func(ctx *Context) {
+		ctx.RespString(http.StatusOK, "parent")
+	})
+	s.GET("/slow", func(ctx *Context) {
+		close(slowStarted)
+		time.Sleep(300 * time.Millisecond)
+		ctx.RespString(http.StatusOK, "parent slow")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := "http://" + ln.Addr().String()
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ln)
+	}()
+	get := func(path string) (string, error) {
+		resp, err := (&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}).Get(addr + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+	body, err := get("/")
+	require.NoError(t, err)
+	assert.Equal(t, "parent", body)
+
+	slow := make(chan string, 1)
+	go func() {
+		body, err := get("/slow")
+		if err != nil {
+			body = err.Error()
+		}
+		slow <- body
+	}()
+	<-slowStarted
+
+	sigs := make(chan os.Signal, 2)
+	// 第一次子进程启动失败，父进程继续提供服务，第二次成功
+	sigs <- syscall.SIGUSR2
+	sigs <- syscall.SIGUSR2
+	go s.restartLoop(ln, sigs)
+
+	select {
+	case err = <-served:
+		require.NoError(t, err)
+	case <-time.After(15 * time.Second):
+		t.Fatal("父进程没有退出")
+	}
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "第一个子进程没有启动")
+	assert.Equal(t, "parent slow", <-slow)
+
+	body, err = get("/")
+	require.NoError(t, err)
+	assert.Equal(t, "child", body)
+	_, _ = get("/quit")
+}
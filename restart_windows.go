@@ -0,0 +1,20 @@
+//go:build windows
+
+package web
+
+import (
+	"log"
+	"net"
+)
+
+// Windows 下既没有 SIGUSR2，也无法通过 ExtraFiles 传递 socket，所以不支持热重启
+
+func inheritedListener() (net.Listener, bool, error) {
+	return nil, false, nil
+}
+
+func notifyReady() {}
+
+func (h *HTTPServer) watchRestart(ln net.Listener) {
+	log.Println("web: windows 下不支持热重启")
+}
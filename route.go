@@ -2,15 +2,48 @@ package web
 
 import (
 	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
 	"strings"
 )
 
 type HandleFunc func(ctx *Context)
 
+// RouteInfo 注册的路由，可以用来生成接口文档
+type RouteInfo struct {
+	Method string
+	Path   string
+	// Name 路由的名字，用于 URL 反向生成路径，可以为空
+	Name string
+	// Req 和 Resp 是 typed handler 的请求和响应类型，普通的 HandleFunc 为 nil
+	Req  reflect.Type
+	Resp reflect.Type
+}
+
+// RouteOption 注册路由的时候附加的信息
+type RouteOption func(info *RouteInfo)
+
+// RouteWithName 给路由起名字，之后可以通过 URL 生成路径，名字不能重复
+func RouteWithName(name string) RouteOption {
+	return func(info *RouteInfo) {
+		info.Name = name
+	}
+}
+
+// Routable HTTPServer 和 RouteGroup 都可以注册路由
+type Routable interface {
+	Handle(method string, path string, handler HandleFunc, opts ...RouteOption)
+}
+
 type router struct {
 	// trees 是按照 HTTP 方法来组织的
 	// 如 GET => *node
 	trees map[string]*node
+	// routes 按照注册顺序记录的路由
+	routes []RouteInfo
+	// names 路由的名字 => 路由的路径
+	names map[string]string
 }
 
 func newRouter() router {
@@ -26,7 +59,7 @@ func newRouter() router {
 // - 不能在同一个位置注册不同的参数路由，例如 /user/:id 和 /user/:name 冲突
 // - 不能在同一个位置同时注册通配符路由和参数路由，例如 /user/:id 和 /user/* 冲突
 // - 同名路径参数，在路由匹配的时候，值会被覆盖。例如 /user/:id/abc/:id，那么 /user/123/abc/456 最终 id = 456
-func (r *router) addRoute(method string, path string, handler HandleFunc) {
+func (r *router) addRoute(method string, path string, handler HandleFunc, opts ...RouteOption) {
 	if path == "" {
 		panic("web: 路由是空字符串")
 	}
@@ -37,6 +70,13 @@ func (r *router) addRoute(method string, path string, handler HandleFunc) {
 	if path != "/" && path[len(path) - 1] == '/' {
 		panic("web: 路由不能以 / 结尾")
 	}
+	info := RouteInfo{Method: method, Path: path}
+	for _, opt := range opts {
+		opt(&info)
+	}
+	if _, ok := r.names[info.Name]; ok && info.Name != "" {
+		panic(fmt.Sprintf("web: 路由名字冲突[%s]", info.Name))
+	}
 
 	root, ok := r.trees[method]
 	if !ok {
@@ -49,6 +89,7 @@ func (r *router) addRoute(method string, path string, handler HandleFunc) {
 			panic("web: 路由冲突[/]")
 		}
 		root.handler = handler
+		r.recordRoute(info)
 		return
 	}
 
@@ -65,6 +106,61 @@ func (r *router) addRoute(method string, path string, handler HandleFunc) {
 	}
 	root.handler = handler
 	root.route = path
+	r.recordRoute(info)
+}
+
+func (r *router) recordRoute(info RouteInfo) {
+	r.routes = append(r.routes, info)
+	if info.Name == "" {
+		return
+	}
+	if r.names == nil {
+		r.names = make(map[string]string)
+	}
+	r.names[info.Name] = info.Path
+}
+
+// URL 根据路由的名字生成路径，params 是路径参数，例如
+// 路由 /users/:id 的名字是 users.show，那么 URL("users.show", map[string]string{"id": "123"}) 返回 /users/123
+// 通配符 * 对应的参数名就是 *
+func (r *router) URL(name string, params map[string]string) (string, error) {
+	path, ok := r.names[name]
+	if !ok {
+		return "", fmt.Errorf("web: 路由 %s 不存在", name)
+	}
+	if path == "/" {
+		return path, nil
+	}
+	segs := strings.Split(path[1:], "/")
+	for i, s := range segs {
+		key := s
+		switch {
+		case s == "*":
+		case s[0] == ':':
+			key = s[1:]
+		default:
+			continue
+		}
+		val, ok := params[key]
+		if !ok {
+			return "", fmt.Errorf("web: 生成路由 %s 缺少参数 %s", name, key)
+		}
+		segs[i] = url.PathEscape(val)
+	}
+	return "/" + strings.Join(segs, "/"), nil
+}
+
+// Routes 返回所有注册的路由，按照路径和 HTTP 方法排序
+func (r *router) Routes() []RouteInfo {
+	res := make([]RouteInfo, len(r.routes))
+	copy(res, r.routes)
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Path != res[j].Path {
+			return res[i].Path < res[j].Path
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
 }
 
 // findRoute 查找对应的节点
@@ -1,9 +1,19 @@
 package web
 
 import (
+	"context"
+	"errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Server interface {
@@ -15,7 +25,7 @@ type Server interface {
 
 	// addRoute 注册一个路由
 	// method 是 HTTP 方法
-	addRoute(method string, path string, handler HandleFunc)
+	addRoute(method string, path string, handler HandleFunc, opts ...RouteOption)
 	// 我们并不采取这种设计方案
 	// addRoute(method string, path string, handlers... HandleFunc)
 }
@@ -28,19 +38,178 @@ type HTTPServer struct {
 	// 组合 router
 	router
 	mdls []Middleware
+	// handler 组装好的 middleware 调用链，
+	// 在创建 HTTPServer 以及调用 Use 的时候组装，而不是每个请求都组装一遍
+	handler HandleFunc
+	// ctxPool 复用 Context
+	ctxPool sync.Pool
 	tplEngine TemplateEngine
+	// errHandler 处理 HandleFuncE 返回的 error
+	errHandler ErrorHandler
+	// validator 绑定参数之后的校验
+	validator *Validator
+	// codecs 按照 Content-Type 注册的编解码器
+	codecs codecs
+	// cookieKeys 签名和加密 cookie 的密钥
+	cookieKeys *cookieKeys
+	// maxBodySize 请求体大小的上限，0 表示不限制
+	maxBodySize int64
+	// trustedProxies 受信任的代理，只有来自它们的 X-Forwarded-For 之类的请求头才会被使用
+	trustedProxies []netip.Prefix
+	// errCodes 业务码的注册表，Enveloped 没有指定的时候使用
+	errCodes *ErrCodes
+
+	// srv 真正负责网络通信的 http.Server，
+	// 超时、请求头大小之类的参数都是通过 HTTPServerOption 设置在它上面的
+	srv *http.Server
+	// srvOpts 设置 srv 参数的 option，在确定 srv 之后才执行，
+	// 这样不管 ServerWithHTTPServer 放在什么位置，这些设置都不会丢失
+	srvOpts []func(srv *http.Server)
+	// 连接状态变更的回调
+	connStateHooks []func(conn net.Conn, state http.ConnState)
+	// 连接状态的统计
+	connStats *connTracker
+	keepAlivesDisabled bool
+	// 是否支持不加密的 HTTP/2
+	h2c bool
+
+	// 热重启相关配置
+	hotRestart bool
+	// 父进程优雅退出的最长等待时间
+	shutdownTimeout time.Duration
+	// 父进程等待子进程就绪的最长时间，超时之后放弃这一次热重启
+	readyTimeout time.Duration
+	// 标记当前进程正在因为热重启而退出
+	restarting atomic.Bool
+	// 热重启时父进程排空请求的结果
+	drained chan error
 }
 
 func NewHTTPServer(opts ...HTTPServerOption) *HTTPServer {
 	server := &HTTPServer{
 		router: newRouter(),
+		shutdownTimeout: 30 * time.Second,
+		readyTimeout: 30 * time.Second,
+		drained: make(chan error, 1),
+		connStats: newConnTracker(),
+		errHandler: DefaultErrorHandler,
+		validator: defaultValidator,
+		codecs: newCodecs(),
+	}
+	server.ctxPool.New = func() any {
+		return &Context{}
 	}
 	for _, opt := range opts {
 		opt(server)
 	}
+	if server.srv == nil {
+		// ReadTimeout 和 WriteTimeout 会影响长连接以及流式响应，所以默认不设置
+		server.srv = &http.Server{IdleTimeout: 2 * time.Minute}
+	}
+	if server.srv.ReadHeaderTimeout == 0 {
+		// 默认值一定要设置，否则很容易被慢速攻击（slowloris）拖垮，
+		// 用户自己创建的 http.Server 也一样
+		server.srv.ReadHeaderTimeout = 10 * time.Second
+	}
+	server.srv.Handler = server
+	for _, opt := range server.srvOpts {
+		opt(server.srv)
+	}
+	server.buildChain()
+	server.srv.ConnState = server.onConnState
+	server.srv.SetKeepAlivesEnabled(!server.keepAlivesDisabled)
+	if server.h2c {
+		// h2c 的连接不会经过 http.Server 自己的 HTTP/2 实现，
+		// 所以超时之类的设置需要同步一份过去
+		server.srv.Handler = h2c.NewHandler(server, &http2.Server{
+			IdleTimeout: server.srv.IdleTimeout,
+		})
+	}
 	return server
 }
 
+// ServerWithHTTPServer 使用用户自己创建的 http.Server。
+// srv.Handler 和 srv.ConnState 会被覆盖，连接状态的回调请使用 ServerWithConnState。
+// 其它设置 http.Server 参数的 option 不管放在前面还是后面，都会作用在 srv 上，
+// srv 没有设置 ReadHeaderTimeout 的时候，使用默认的 10 秒
+func ServerWithHTTPServer(srv *http.Server) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.srv = srv
+	}
+}
+
+// withSrv 设置 http.Server 参数的 option
+func withSrv(fn func(srv *http.Server)) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.srvOpts = append(server.srvOpts, fn)
+	}
+}
+
+// ServerWithReadHeaderTimeout 读取请求头的超时时间
+func ServerWithReadHeaderTimeout(timeout time.Duration) HTTPServerOption {
+	return withSrv(func(srv *http.Server) {
+		srv.ReadHeaderTimeout = timeout
+	})
+}
+
+// ServerWithReadTimeout 读取整个请求（包括请求体）的超时时间
+func ServerWithReadTimeout(timeout time.Duration) HTTPServerOption {
+	return withSrv(func(srv *http.Server) {
+		srv.ReadTimeout = timeout
+	})
+}
+
+// ServerWithWriteTimeout 写响应的超时时间
+func ServerWithWriteTimeout(timeout time.Duration) HTTPServerOption {
+	return withSrv(func(srv *http.Server) {
+		srv.WriteTimeout = timeout
+	})
+}
+
+// ServerWithIdleTimeout 开启 keep-alive 的时候，连接等待下一个请求的超时时间
+func ServerWithIdleTimeout(timeout time.Duration) HTTPServerOption {
+	return withSrv(func(srv *http.Server) {
+		srv.IdleTimeout = timeout
+	})
+}
+
+// ServerWithMaxHeaderBytes 请求头的最大字节数
+func ServerWithMaxHeaderBytes(n int) HTTPServerOption {
+	return withSrv(func(srv *http.Server) {
+		srv.MaxHeaderBytes = n
+	})
+}
+
+// ServerWithErrorLog 记录连接错误、handler panic 之类的日志
+func ServerWithErrorLog(l *log.Logger) HTTPServerOption {
+	return withSrv(func(srv *http.Server) {
+		srv.ErrorLog = l
+	})
+}
+
+// ServerWithConnState 注册连接状态变更的回调，可以注册多个，按照注册顺序执行
+func ServerWithConnState(hooks ...func(conn net.Conn, state http.ConnState)) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.connStateHooks = append(server.connStateHooks, hooks...)
+	}
+}
+
+// ServerWithH2C 支持不加密的 HTTP/2（h2c），
+// 客户端既可以直接使用 HTTP/2（prior knowledge），也可以通过 Upgrade: h2c 从 HTTP/1.1 升级。
+// 请求依旧会经过同样的 middleware，拿到的也是同样的 Context
+func ServerWithH2C() HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.h2c = true
+	}
+}
+
+// ServerWithKeepAlives 是否开启 HTTP keep-alive，默认开启
+func ServerWithKeepAlives(enabled bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.keepAlivesDisabled = !enabled
+	}
+}
+
 func ServerWithTemplateEngine(tplEngine TemplateEngine) HTTPServerOption {
 	return func(server *HTTPServer) {
 		server.tplEngine = tplEngine
@@ -53,28 +222,19 @@ func ServerWithMiddleware(mdls ...Middleware) HTTPServerOption {
 	}
 }
 
+// Use 注册 middleware，并重新组装调用链
+// 注意 Use 并不是并发安全的，应该在 Start 之前调用
 func (h *HTTPServer) Use(mdls ...Middleware) {
 	if h.mdls == nil {
 		h.mdls = mdls
-		return
+	} else {
+		h.mdls = append(h.mdls, mdls...)
 	}
-	h.mdls = append(h.mdls, mdls...)
+	h.buildChain()
 }
 
-// Start 启动服务器
-func (h *HTTPServer) Start(addr string) error {
-	err := http.ListenAndServe(addr, h)
-	return err
-}
-
-// ServeHTTP HTTPServer 处理请求的入口
-func (h *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	// 封装请求与响应
-	ctx := &Context{
-		Req: request,
-		Resp: writer,
-		tplEngine: h.tplEngine,
-	}
+// buildChain 组装 middleware 调用链
+func (h *HTTPServer) buildChain() {
 	// 最后一个应该是 HTTPServer 执行路由匹配，执行用户代码
 	root := h.server
 	// 从后往前组装
@@ -90,29 +250,175 @@ func (h *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request
 			h.flashResp(ctx)
 		}
 	}
-	root = m(root)
-	root(ctx)
+	h.handler = m(root)
+}
+
+// ServerWithHotRestart 开启热重启。
+// 收到 SIGUSR2 信号之后，当前进程会把监听的 socket 传递给新启动的子进程，
+// 子进程开始接收新连接，而当前进程在 timeout 内处理完已有请求后退出
+func ServerWithHotRestart(timeout time.Duration) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.hotRestart = true
+		server.shutdownTimeout = timeout
+	}
+}
+
+// ServerWithRestartReadyTimeout 热重启时父进程等待子进程就绪的最长时间，默认是 30 秒，
+// 超时之后杀死子进程，父进程继续提供服务
+func ServerWithRestartReadyTimeout(timeout time.Duration) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.readyTimeout = timeout
+	}
+}
+
+// Start 启动服务器
+// 如果开启了热重启，并且当前进程是热重启拉起来的子进程，那么会直接使用父进程传递过来的 socket
+func (h *HTTPServer) Start(addr string) error {
+	ln, err := h.listen(addr)
+	if err != nil {
+		return err
+	}
+	return h.Serve(ln)
+}
+
+// Serve 在已有的 listener 上面提供服务
+func (h *HTTPServer) Serve(ln net.Listener) error {
+	if h.hotRestart {
+		go h.watchRestart(ln)
+		// 热重启拉起来的子进程，到这里已经可以提供服务了，通知父进程开始退出
+		notifyReady()
+	}
+	err := h.srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) && h.restarting.Load() {
+		// Serve 在开始 Shutdown 的时候就返回了，
+		// 这里要等已有的请求处理完毕才能退出
+		return <-h.drained
+	}
+	return err
+}
+
+func (h *HTTPServer) listen(addr string) (net.Listener, error) {
+	// 没有开启热重启的服务器，例如同一个进程里面的 metrics 服务器，不能抢走父进程传递过来的 socket
+	if !h.hotRestart {
+		return net.Listen("tcp", addr)
+	}
+	ln, ok, err := inheritedListener()
+	if err != nil || ok {
+		return ln, err
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Shutdown 优雅退出，不再接收新的连接，并等待已有的请求处理完毕
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
+	return h.srv.Shutdown(ctx)
+}
+
+// ConnStats 返回当前连接状态的统计
+func (h *HTTPServer) ConnStats() ConnStats {
+	return h.connStats.stats()
+}
+
+func (h *HTTPServer) onConnState(conn net.Conn, state http.ConnState) {
+	h.connStats.track(conn, state)
+	for _, hook := range h.connStateHooks {
+		hook(conn, state)
+	}
+}
+
+// ServeHTTP HTTPServer 处理请求的入口
+func (h *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	// 封装请求与响应
+	// Context 会被复用，所以请求处理完毕之后，不要再持有 Context，
+	// 例如在 handler 里面开启的 goroutine 中使用它，这种 goroutine 应该使用 ctx.Values() 或者 ctx.Req.Context()
+	ctx := h.ctxPool.Get().(*Context)
+	ctx.Req = request
+	// 在执行 handler 之前就替换好 ctx.Req，之后的 Set 不会再修改 ctx.Req，
+	// 这样 handler 启动的 goroutine 读取 ctx.Req 的时候不会有数据竞争
+	ctx.bindValues()
+	ctx.resp.reset(writer)
+	ctx.Resp = &ctx.resp
+	ctx.tplEngine = h.tplEngine
+	ctx.errHandler = h.errHandler
+	ctx.validator = h.validator
+	ctx.codecs = h.codecs
+	ctx.cookieKeys = h.cookieKeys
+	ctx.trustedProxies = h.trustedProxies
+	ctx.errCodes = h.errCodes
+	ctx.rawBody = ctx.Req.Body
+	if h.maxBodySize > 0 {
+		ctx.limitBody(h.maxBodySize)
+	}
+	h.handler(ctx)
+	if ctx.sse != nil {
+		// Context 马上就要被复用了，心跳之类的 goroutine 不能再写响应
+		ctx.sse.Close()
+	}
+	ctx.reset()
+	h.ctxPool.Put(ctx)
 }
 
 func (h *HTTPServer) server(ctx *Context) {
 	// 查找路由
 	n, ok := h.findRoute(ctx.Req.Method, ctx.Req.URL.Path)
 	if !ok || n.n.handler == nil {
-		ctx.Resp.WriteHeader(http.StatusNotFound)
-		_, _ = ctx.Resp.Write([]byte("NOT FOUND"))
+		if allowed := h.allowedMethods(ctx.Req.URL.Path); len(allowed) > 0 {
+			ctx.Resp.Header().Set("Allow", strings.Join(allowed, ", "))
+			ctx.respError(http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		ctx.respError(http.StatusNotFound, "NOT FOUND")
 		return
 	}
 	ctx.PathParams = n.pathParams
 	ctx.MatchedRoute = n.n.route
 	n.n.handler(ctx)
+	h.syncStatus(ctx)
+}
+
+// allowedMethods 路径能够匹配上的其它 HTTP 方法，用于响应 405
+func (h *HTTPServer) allowedMethods(path string) []string {
+	var res []string
+	for method := range h.trees {
+		if mi, ok := h.findRoute(method, path); ok && mi.n.handler != nil {
+			res = append(res, method)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// syncStatus 如果 handler 绕开 RespStatusCode 直接写了响应，
+// 那么把真实的响应码同步回来，这样 middleware 才能拿到正确的响应码
+func (h *HTTPServer) syncStatus(ctx *Context) {
+	if ctx.Resp.Written() && ctx.RespStatusCode == 0 {
+		ctx.RespStatusCode = ctx.Resp.Status()
+	}
+}
+
+// Handle 注册任意 HTTP 方法的路由
+func (h *HTTPServer) Handle(method string, path string, handler HandleFunc, opts ...RouteOption) {
+	h.addRoute(method, path, handler, opts...)
+}
+
+func (h *HTTPServer) GET(path string, handler HandleFunc, opts ...RouteOption) {
+	h.addRoute(http.MethodGet, path, handler, opts...)
+}
+
+func (h *HTTPServer) POST(path string, handler HandleFunc, opts ...RouteOption) {
+	h.addRoute(http.MethodPost, path, handler, opts...)
 }
 
-func (h *HTTPServer) GET(path string, handler HandleFunc) {
-	h.addRoute(http.MethodGet, path, handler)
+func (h *HTTPServer) PUT(path string, handler HandleFunc, opts ...RouteOption) {
+	h.addRoute(http.MethodPut, path, handler, opts...)
 }
 
-func (h *HTTPServer) POST(path string, handler HandleFunc) {
-	h.addRoute(http.MethodPost, path, handler)
+func (h *HTTPServer) PATCH(path string, handler HandleFunc, opts ...RouteOption) {
+	h.addRoute(http.MethodPatch, path, handler, opts...)
+}
+
+func (h *HTTPServer) DELETE(path string, handler HandleFunc, opts ...RouteOption) {
+	h.addRoute(http.MethodDelete, path, handler, opts...)
 }
 
 func minOperations(s1 string, s2 string, x int) int {
@@ -128,9 +434,17 @@ func countOnes(s string) int {
 }
 
 func (h *HTTPServer) flashResp(ctx *Context) {
+	// 响应已经被直接写回去了，例如 FileDownloader 或者 Hijack 了连接
+	if ctx.Resp.Written() {
+		return
+	}
 	if ctx.RespStatusCode > 0 {
 		ctx.Resp.WriteHeader(ctx.RespStatusCode)
 	}
+	// 204、304 之类的响应不允许有响应体，哪怕是写入空数据也会报错
+	if len(ctx.RespData) == 0 {
+		return
+	}
 	_, err := ctx.Resp.Write(ctx.RespData)
 	if err != nil {
 		log.Fatalln("回写响应失败", err)
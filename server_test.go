@@ -0,0 +1,284 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPServer_ConnState(t *testing.T) {
+	var mutex sync.Mutex
+	var states []http.ConnState
+	s := NewHTTPServer(
+		ServerWithReadHeaderTimeout(time.Second),
+		ServerWithKeepAlives(false),
+		ServerWithConnState(func(conn net.Conn, state http.ConnState) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			states = append(states, state)
+		}))
+	s.GET("/", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = s.Serve(ln)
+	}()
+	defer func() {
+		_ = s.Shutdown(context.Background())
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "hello", string(data))
+	// 关闭了 keep-alive，所以服务端会主动关闭连接
+	assert.True(t, resp.Close)
+
+	assert.Eventually(t, func() bool {
+		return s.ConnStats().Closed == 1
+	}, time.Second, 10*time.Millisecond)
+	stats := s.ConnStats()
+	assert.Equal(t, int64(1), stats.Accepted)
+	assert.Equal(t, int64(0), stats.Active+stats.Idle+stats.New)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []http.ConnState{http.StateNew, http.StateActive, http.StateClosed}, states)
+}
+
+func TestHTTPServer_ReadHeaderTimeout(t *testing.T) {
+	s := NewHTTPServer(ServerWithReadHeaderTimeout(100 * time.Millisecond))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = s.Serve(ln)
+	}()
+	defer func() {
+		_ = s.Shutdown(context.Background())
+	}()
+
+	// 模拟慢速攻击，请求头一直发不完
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n"))
+	require.NoError(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadAll(conn)
+	// 服务端超时之后会主动关闭连接，而不是等到我们的读超时
+	assert.NoError(t, err)
+}
+
+func TestServerWithHTTPServer(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts func(srv *http.Server) []HTTPServerOption
+		// wantReadHeaderTimeout 用户没有设置的时候，依旧使用默认值
+		wantReadHeaderTimeout time.Duration
+		wantReadTimeout       time.Duration
+	}{
+		{
+			name: "options after",
+			opts: func(srv *http.Server) []HTTPServerOption {
+				return []HTTPServerOption{ServerWithHTTPServer(srv), ServerWithReadTimeout(time.Second)}
+			},
+			wantReadHeaderTimeout: 10 * time.Second,
+			wantReadTimeout:       time.Second,
+		},
+		{
+			name: "options before",
+			opts: func(srv *http.Server) []HTTPServerOption {
+				return []HTTPServerOption{ServerWithReadTimeout(time.Second), ServerWithHTTPServer(srv)}
+			},
+			wantReadHeaderTimeout: 10 * time.Second,
+			wantReadTimeout:       time.Second,
+		},
+		{
+			name: "user read header timeout",
+			opts: func(srv *http.Server) []HTTPServerOption {
+				srv.ReadHeaderTimeout = 3 * time.Second
+				return []HTTPServerOption{ServerWithHTTPServer(srv)}
+			},
+			wantReadHeaderTimeout: 3 * time.Second,
+		},
+		{
+			name: "option overrides user",
+			opts: func(srv *http.Server) []HTTPServerOption {
+				srv.ReadHeaderTimeout = 3 * time.Second
+				return []HTTPServerOption{ServerWithReadHeaderTimeout(time.Second), ServerWithHTTPServer(srv)}
+			},
+			wantReadHeaderTimeout: time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := &http.Server{}
+			s := NewHTTPServer(tc.opts(srv)...)
+			assert.Same(t, srv, s.srv)
+			assert.Equal(t, s, srv.Handler)
+			assert.Equal(t, tc.wantReadHeaderTimeout, srv.ReadHeaderTimeout)
+			assert.Equal(t, tc.wantReadTimeout, srv.ReadTimeout)
+		})
+	}
+}
+
+func TestHTTPServer_H2C(t *testing.T) {
+	s := NewHTTPServer(ServerWithH2C())
+	var proto string
+	s.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			proto = ctx.Req.Proto
+			next(ctx)
+		}
+	})
+	s.GET("/user/:id", func(ctx *Context) {
+		id, _ := ctx.PathValue("id").String()
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("user " + id)
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = s.Serve(ln)
+	}()
+	defer func() {
+		_ = s.Shutdown(context.Background())
+	}()
+
+	t.Run("prior knowledge", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				},
+			},
+		}
+		resp, err := client.Get("http://" + ln.Addr().String() + "/user/123")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.Equal(t, "user 123", string(data))
+		assert.Equal(t, "HTTP/2.0", proto)
+	})
+
+	t.Run("upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("GET /user/456 HTTP/1.1\r\n" +
+			"Host: localhost\r\n" +
+			"Connection: Upgrade, HTTP2-Settings\r\n" +
+			"Upgrade: h2c\r\n" +
+			// 空的 SETTINGS 帧
+			"HTTP2-Settings: \r\n\r\n"))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		assert.Equal(t, "h2c", resp.Header.Get("Upgrade"))
+	})
+}
+
+func TestHTTPServer_Use(t *testing.T) {
+	s := NewHTTPServer()
+	var logs []string
+	s.GET("/", func(ctx *Context) {
+		logs = append(logs, "handler")
+	})
+	s.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			logs = append(logs, "first")
+			next(ctx)
+		}
+	})
+	// 第二次 Use 之后调用链要重新组装
+	s.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			logs = append(logs, "second")
+			next(ctx)
+		}
+	})
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"first", "second", "handler"}, logs)
+}
+
+func BenchmarkHTTPServer_ServeHTTP(b *testing.B) {
+	mdls := make([]Middleware, 0, 8)
+	for i := 0; i < 8; i++ {
+		mdls = append(mdls, func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				next(ctx)
+			}
+		})
+	}
+	s := NewHTTPServer(ServerWithMiddleware(mdls...))
+	s.GET("/user/:id", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/user/123", nil)
+
+	b.Run("precomposed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	})
+
+	// 之前的实现：每个请求都创建 Context 并且重新组装调用链
+	b.Run("per request", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx := &Context{Req: req, Resp: &responseWriter{ResponseWriter: httptest.NewRecorder()}}
+			root := s.server
+			for j := len(s.mdls) - 1; j >= 0; j-- {
+				root = s.mdls[j](root)
+			}
+			var m Middleware = func(next HandleFunc) HandleFunc {
+				return func(ctx *Context) {
+					next(ctx)
+					s.flashResp(ctx)
+				}
+			}
+			m(root)(ctx)
+		}
+	})
+}
+
+func TestHTTPServer_ContextReuse(t *testing.T) {
+	s := NewHTTPServer()
+	s.GET("/user/:id", func(ctx *Context) {
+		ctx.UserValues = map[string]any{"id": ctx.PathParams["id"]}
+		ctx.RespStatusCode = http.StatusCreated
+	})
+	s.GET("/home", func(ctx *Context) {
+		// 复用的 Context 不能残留上一个请求的数据
+		assert.Nil(t, ctx.PathParams)
+		assert.Empty(t, ctx.UserValues)
+		assert.Equal(t, 0, ctx.RespStatusCode)
+	})
+	for i := 0; i < 10; i++ {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/123", nil))
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/home", nil))
+	}
+}
@@ -3,6 +3,7 @@ package session
 import (
 	web "github.com/Ai-feier/geek-web"
 	"github.com/google/uuid"
+	"sync"
 )
 
 type Manager struct {
@@ -10,15 +11,22 @@ type Manager struct {
 	Propagator
 	// sessionId
 	CtxSessKey string
+
+	// ctxKey 在 web.Context 上缓存 Session 的 key，每个 Manager 一个，不会互相冲突
+	keyOnce sync.Once
+	ctxKey  *web.Key[Session]
+}
+
+func (m *Manager) key() *web.Key[Session] {
+	m.keyOnce.Do(func() {
+		m.ctxKey = web.NewKey[Session](m.CtxSessKey)
+	})
+	return m.ctxKey
 }
 
 func (m *Manager) GetSession(ctx *web.Context) (Session, error) {
-	if ctx.UserValues == nil {
-		ctx.UserValues = make(map[string]any, 1)
-	}
-	val, ok := ctx.UserValues[m.CtxSessKey]  // 查缓存
-	if ok {
-		return val.(Session), nil
+	if sess, ok := web.Get(ctx, m.key()); ok { // 查缓存
+		return sess, nil
 	}
 	// 缓存中不存在 Session, 从 c.Req 中提取
 	sessionId, err := m.Extract(ctx.Req)
@@ -30,7 +38,7 @@ func (m *Manager) GetSession(ctx *web.Context) (Session, error) {
 	if err != nil {
 		return nil, err
 	}
-	ctx.UserValues[sessionId] = sessionId
+	web.Set(ctx, m.key(), sess)
 	return sess, nil
 }
 
@@ -40,6 +48,7 @@ func (m *Manager) InitSession(ctx *web.Context) (Session, error) {
 	if err != nil {
 		return nil, err
 	}
+	web.Set(ctx, m.key(), sess)
 	// 把 sess_id 注入到响应中
 	err = m.Inject(id, ctx.Resp)
 	return sess, err
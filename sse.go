@@ -0,0 +1,187 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errSSEUnsupported = errors.New("web: ResponseWriter 不支持 Flush，无法使用 SSE")
+	// ErrSSEClosed SSEStream 已经关闭了，或者客户端已经断开连接
+	ErrSSEClosed = errors.New("web: SSE 连接已经关闭")
+)
+
+// SSEStream Server-Sent Events 的响应流
+// 每次发送都会立刻写回并且 Flush，不经过 RespData，所以 middleware 不会缓存事件，
+// 但是也没办法修改事件。所有方法都可以在多个 goroutine 中同时调用
+type SSEStream struct {
+	w http.ResponseWriter
+	// json 编码 Send 的数据，创建的时候就确定下来，因为 handler 返回之后 Context 会被复用
+	json Codec
+	// streamCtx 客户端断开连接或者 Close 的时候结束
+	streamCtx context.Context
+	cancel    context.CancelFunc
+
+	mu sync.Mutex
+}
+
+// SSE 开始 Server-Sent Events 响应，写回响应头并且立刻 Flush
+// handler 返回之后会自动关闭 SSEStream，例如
+//
+//	stream, err := ctx.SSE()
+//	if err != nil { return err }
+//	stream.Heartbeat(15 * time.Second)
+//	for {
+//		select {
+//		case <-stream.Done():
+//			return nil
+//		case msg := <-msgs:
+//			if err := stream.Send("message", msg.ID, msg); err != nil { return err }
+//		}
+//	}
+func (c *Context) SSE() (*SSEStream, error) {
+	if c.sse != nil {
+		return c.sse, nil
+	}
+	if !canFlush(c.Resp) {
+		return nil, errSSEUnsupported
+	}
+	header := c.Resp.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 告诉 nginx 之类的反向代理不要缓存响应
+	header.Set("X-Accel-Buffering", "no")
+	// SSE 是长连接，不能受到 http.Server 的 WriteTimeout 的限制
+	_ = http.NewResponseController(c.Resp).SetWriteDeadline(time.Time{})
+	json, err := c.codec(MIMEJSON)
+	if err != nil {
+		return nil, err
+	}
+	c.Resp.WriteHeader(http.StatusOK)
+	streamCtx, cancel := context.WithCancel(c.Req.Context())
+	c.sse = &SSEStream{w: c.Resp, json: json, streamCtx: streamCtx, cancel: cancel}
+	if err := c.sse.flush(); err != nil {
+		return nil, err
+	}
+	return c.sse, nil
+}
+
+// canFlush 看最里面的 ResponseWriter 是不是支持 Flush，中间的包装层一般都会转发 Flush
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	_, ok := w.(http.Flusher)
+	return ok
+}
+
+// Done 客户端断开连接或者 SSEStream 关闭的时候，返回的 channel 会被关闭
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.streamCtx.Done()
+}
+
+// Send 发送一个事件，event 和 id 为空的时候不发送对应的字段
+// data 是 string 或者 []byte 的时候原样发送，多行数据会拆分为多个 data 字段，
+// 其它类型编码为 JSON
+func (s *SSEStream) Send(event, id string, data any) error {
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n") {
+		return fmt.Errorf("web: SSE 的 event 和 id 不能包含换行符")
+	}
+	var payload string
+	switch val := data.(type) {
+	case string:
+		payload = val
+	case []byte:
+		payload = string(val)
+	default:
+		encoded, err := s.json.Encode(val)
+		if err != nil {
+			return err
+		}
+		payload = string(encoded)
+	}
+
+	var sb strings.Builder
+	if event != "" {
+		sb.WriteString("event: " + event + "\n")
+	}
+	if id != "" {
+		sb.WriteString("id: " + id + "\n")
+	}
+	payload = strings.ReplaceAll(payload, "\r\n", "\n")
+	for _, line := range strings.Split(payload, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	return s.write(sb.String())
+}
+
+// Retry 告诉客户端断开之后，等待多久重新连接
+func (s *SSEStream) Retry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// Comment 发送注释，客户端会忽略，一般用于保持连接
+func (s *SSEStream) Comment(text string) error {
+	var sb strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		sb.WriteString(": " + line + "\n")
+	}
+	sb.WriteString("\n")
+	return s.write(sb.String())
+}
+
+// Heartbeat 每隔 interval 发送一次注释，避免连接因为空闲被代理断开
+// 直到客户端断开连接或者 SSEStream 关闭
+func (s *SSEStream) Heartbeat(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.Comment("ping") != nil {
+					return
+				}
+			case <-s.streamCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close 关闭之后再发送会返回 ErrSSEClosed
+// 一般不需要手动调用，handler 返回之后会自动关闭
+func (s *SSEStream) Close() {
+	// 拿到锁再取消，保证 Close 返回之后不会再有任何写入
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel()
+}
+
+func (s *SSEStream) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streamCtx.Err() != nil {
+		return ErrSSEClosed
+	}
+	if _, err := s.w.Write([]byte(data)); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (s *SSEStream) flush() error {
+	return http.NewResponseController(s.w).Flush()
+}
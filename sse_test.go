@@ -0,0 +1,97 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContext_SSE(t *testing.T) {
+	handlerDone := make(chan error, 1)
+	status := make(chan int, 1)
+	s := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			status <- ctx.RespStatusCode
+		}
+	}))
+	s.GET("/events", func(ctx *Context) {
+		stream, err := ctx.SSE()
+		if err != nil {
+			handlerDone <- err
+			return
+		}
+		_ = stream.Retry(3 * time.Second)
+		_ = stream.Send("greeting", "1", "hello\nworld")
+		_ = stream.Send("", "2", map[string]string{"name": "Tom"})
+		stream.Heartbeat(10 * time.Millisecond)
+		// 一直等到客户端断开连接
+		<-stream.Done()
+		handlerDone <- stream.Send("", "", "bye")
+	})
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, server.URL+"/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	// handler 还没有返回，事件就已经到了
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var sb strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return sb.String()
+			}
+			sb.WriteString(line)
+		}
+	}
+	assert.Equal(t, "retry: 3000\n", readEvent())
+	assert.Equal(t, "event: greeting\nid: 1\ndata: hello\ndata: world\n", readEvent())
+	assert.Equal(t, "id: 2\ndata: {\"name\":\"Tom\"}\n", readEvent())
+	assert.Equal(t, ": ping\n", readEvent())
+
+	cancel()
+	select {
+	case err = <-handlerDone:
+		assert.Equal(t, ErrSSEClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("客户端断开连接之后 handler 没有退出")
+	}
+	assert.Equal(t, http.StatusOK, <-status)
+}
+
+func TestContext_SSEClose(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil)}
+	ctx.resp.reset(recorder)
+	ctx.Resp = &ctx.resp
+	stream, err := ctx.SSE()
+	require.NoError(t, err)
+	// 再次调用返回同一个
+	again, err := ctx.SSE()
+	require.NoError(t, err)
+	assert.Same(t, stream, again)
+
+	assert.Error(t, stream.Send("bad\nevent", "", "data"))
+	require.NoError(t, stream.Comment("hello"))
+	stream.Close()
+	assert.Equal(t, ErrSSEClosed, stream.Send("", "", "data"))
+	assert.Equal(t, ": hello\n\n", recorder.Body.String())
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrKeyNotFound 请求中没有这个 key
+	ErrKeyNotFound = errors.New("web: 找不到这个 key")
+	// ErrInvalidValue 值无法转化为期望的类型，原始的解析错误也可以通过 errors.As 拿到
+	ErrInvalidValue = errors.New("web: 非法的值")
+)
+
+// StringValue 从请求中读取的字符串，以及读取过程中的错误
+// 可以用 errors.Is(err, ErrKeyNotFound) 判断是没有传这个参数，
+// 还是 errors.Is(err, ErrInvalidValue) 传了但是格式不对
+type StringValue struct {
+	val string
+	err error
+}
+
+func (s StringValue) String() (string, error) {
+	return s.val, s.err
+}
+
+func (s StringValue) AsInt64() (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	res, err := strconv.ParseInt(s.val, 10, 64)
+	return res, s.wrap(err)
+}
+
+func (s StringValue) AsUint64() (uint64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	res, err := strconv.ParseUint(s.val, 10, 64)
+	return res, s.wrap(err)
+}
+
+func (s StringValue) AsFloat64() (float64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	res, err := strconv.ParseFloat(s.val, 64)
+	return res, s.wrap(err)
+}
+
+// AsBool 支持 strconv.ParseBool 支持的所有形式，例如 1、t、true
+func (s StringValue) AsBool() (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	res, err := strconv.ParseBool(s.val)
+	return res, s.wrap(err)
+}
+
+// AsDuration 例如 300ms、1h30m
+func (s StringValue) AsDuration() (time.Duration, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	res, err := time.ParseDuration(s.val)
+	return res, s.wrap(err)
+}
+
+// AsTime 按照 layout 解析时间，例如 time.DateOnly
+func (s StringValue) AsTime(layout string) (time.Time, error) {
+	if s.err != nil {
+		return time.Time{}, s.err
+	}
+	res, err := time.Parse(layout, s.val)
+	return res, s.wrap(err)
+}
+
+// AsInts 解析逗号分隔的整数，例如 1,2,3
+// 空字符串返回空切片
+func (s StringValue) AsInts() ([]int64, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.val == "" {
+		return []int64{}, nil
+	}
+	segs := strings.Split(s.val, ",")
+	res := make([]int64, 0, len(segs))
+	for _, seg := range segs {
+		i, err := strconv.ParseInt(strings.TrimSpace(seg), 10, 64)
+		if err != nil {
+			return nil, s.wrap(err)
+		}
+		res = append(res, i)
+	}
+	return res, nil
+}
+
+// 下面的 OrDefault 方法，不管是找不到 key 还是解析失败，都会返回默认值
+
+func (s StringValue) StringOrDefault(def string) string {
+	if s.err != nil {
+		return def
+	}
+	return s.val
+}
+
+func (s StringValue) AsInt64OrDefault(def int64) int64 {
+	res, err := s.AsInt64()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) AsUint64OrDefault(def uint64) uint64 {
+	res, err := s.AsUint64()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) AsFloat64OrDefault(def float64) float64 {
+	res, err := s.AsFloat64()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) AsBoolOrDefault(def bool) bool {
+	res, err := s.AsBool()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) AsDurationOrDefault(def time.Duration) time.Duration {
+	res, err := s.AsDuration()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) AsTimeOrDefault(layout string, def time.Time) time.Time {
+	res, err := s.AsTime(layout)
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) AsIntsOrDefault(def []int64) []int64 {
+	res, err := s.AsInts()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w %q: %w", ErrInvalidValue, s.val, err)
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStringValue(t *testing.T) {
+	ctx := &Context{
+		Req: httptest.NewRequest(http.MethodGet,
+			"/?id=123&price=9.9&admin=true&timeout=1m30s&date=2023-10-01&ids=1,%202,3&bad=abc", nil),
+	}
+
+	id, err := ctx.QueryValue("id").AsInt64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), id)
+	uid, err := ctx.QueryValue("id").AsUint64()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(123), uid)
+	price, err := ctx.QueryValue("price").AsFloat64()
+	assert.NoError(t, err)
+	assert.Equal(t, 9.9, price)
+	admin, err := ctx.QueryValue("admin").AsBool()
+	assert.NoError(t, err)
+	assert.True(t, admin)
+	timeout, err := ctx.QueryValue("timeout").AsDuration()
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+	date, err := ctx.QueryValue("date").AsTime(time.DateOnly)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC), date)
+	ids, err := ctx.QueryValue("ids").AsInts()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	// 找不到和解析失败是可以区分的
+	_, err = ctx.QueryValue("missing").AsInt64()
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+	assert.False(t, errors.Is(err, ErrInvalidValue))
+	_, err = ctx.QueryValue("bad").AsInt64()
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	assert.False(t, errors.Is(err, ErrKeyNotFound))
+	var numErr *strconv.NumError
+	assert.True(t, errors.As(err, &numErr))
+	_, err = ctx.QueryValue("ids").AsInt64()
+	assert.EqualError(t, err, `web: 非法的值 "1, 2,3": strconv.ParseInt: parsing "1, 2,3": invalid syntax`)
+	_, err = ctx.PathValue("id").String()
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	assert.Equal(t, int64(10), ctx.QueryValue("missing").AsInt64OrDefault(10))
+	assert.Equal(t, int64(10), ctx.QueryValue("bad").AsInt64OrDefault(10))
+	assert.Equal(t, int64(123), ctx.QueryValue("id").AsInt64OrDefault(10))
+	assert.Equal(t, "abc", ctx.QueryValue("bad").StringOrDefault("def"))
+	assert.Equal(t, "def", ctx.QueryValue("missing").StringOrDefault("def"))
+	assert.Equal(t, []int64{1}, ctx.QueryValue("bad").AsIntsOrDefault([]int64{1}))
+	assert.Equal(t, time.Second, ctx.QueryValue("bad").AsDurationOrDefault(time.Second))
+	assert.False(t, ctx.QueryValue("bad").AsBoolOrDefault(false))
+}
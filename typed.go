@@ -0,0 +1,89 @@
+package web
+
+import (
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"reflect"
+)
+
+// TypedHandleFunc 强类型的业务逻辑，req 由框架绑定和校验，返回值由框架编码
+type TypedHandleFunc[Req any, Resp any] func(ctx *Context, req Req) (Resp, error)
+
+// typedOffers typed handler 能够提供的响应类型，没有 Accept 的时候使用 JSON
+var typedOffers = []string{MIMEJSON, MIMEXML, MIMEMsgpack}
+
+// typedProtoOffers 返回值是 proto.Message 的时候，还可以使用 Protobuf
+var typedProtoOffers = []string{MIMEJSON, MIMEXML, MIMEMsgpack, MIMEProtobuf}
+
+// Typed 将 TypedHandleFunc 转化为 HandleFunc：
+//  1. 依次从查询参数、请求体、路径参数绑定 Req，后面的覆盖前面的，有 Content-Type 并且有请求体的时候才会绑定请求体
+//  2. 使用 Validator 校验 Req
+//  3. 根据 Accept 选择编码方式，以 200 返回 Resp，使用了 Enveloped 的路由会包装成 Envelope
+//
+// 任何一步返回的 error 都和 HandleE 一样交给 ErrorHandler。
+// 需要在文档里面保留 Req 和 Resp 类型的时候，请使用 HandleTyped 注册路由
+func Typed[Req any, Resp any](fn TypedHandleFunc[Req, Resp]) HandleFunc {
+	return HandleE(func(ctx *Context) error {
+		req, err := bindTyped[Req](ctx)
+		if err != nil {
+			return err
+		}
+		resp, err := fn(ctx, req)
+		if err != nil {
+			return err
+		}
+		return ctx.respTyped(resp)
+	})
+}
+
+// HandleTyped 注册 typed handler，并且把 Req 和 Resp 的类型记录到 RouteInfo 里面，例如
+//
+//	web.HandleTyped(server, http.MethodPost, "/users", func(ctx *web.Context, req CreateUserReq) (CreateUserResp, error) {
+//		...
+//	})
+func HandleTyped[Req any, Resp any](r Routable, method string, path string, fn TypedHandleFunc[Req, Resp], opts ...RouteOption) {
+	opts = append(opts, func(info *RouteInfo) {
+		info.Req = reflect.TypeOf((*Req)(nil)).Elem()
+		info.Resp = reflect.TypeOf((*Resp)(nil)).Elem()
+	})
+	r.Handle(method, path, Typed(fn), opts...)
+}
+
+// bindTyped Req 可以是结构体，也可以是结构体指针，
+// 其它类型例如切片，只能从请求体绑定
+func bindTyped[Req any](ctx *Context) (Req, error) {
+	var req Req
+	target := any(&req)
+	if rv := reflect.ValueOf(&req).Elem(); rv.Kind() == reflect.Pointer {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		target = req
+	}
+	isStruct := reflect.TypeOf(target).Elem().Kind() == reflect.Struct
+	if isStruct {
+		if err := ctx.bindQuery(target); err != nil {
+			return req, err
+		}
+	}
+	if ctx.Req.Header.Get("Content-Type") != "" && ctx.Req.ContentLength != 0 {
+		if err := ctx.bindBody(target); err != nil {
+			return req, err
+		}
+	}
+	if isStruct {
+		if err := ctx.bindPath(target); err != nil {
+			return req, err
+		}
+	}
+	return req, ctx.validate(target)
+}
+
+func (c *Context) respTyped(val any) error {
+	if c.enveloped {
+		val = Envelope{Code: CodeOK, Msg: "ok", Data: val}
+	}
+	offers := typedOffers
+	if _, ok := val.(proto.Message); ok {
+		offers = typedProtoOffers
+	}
+	return c.Negotiate(http.StatusOK, Offers{Types: offers, Data: val})
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type typedUpdateUserReq struct {
+	ID     int64  `path:"id" json:"-"`
+	Notify bool   `query:"notify" json:"-"`
+	Name   string `query:"-" json:"name" validate:"required"`
+}
+
+type typedUserResp struct {
+	ID     int64  `json:"id" xml:"id"`
+	Name   string `json:"name" xml:"name"`
+	Notify bool   `json:"notify" xml:"notify"`
+}
+
+func TestTyped(t *testing.T) {
+	updateUser := func(ctx *Context, req typedUpdateUserReq) (typedUserResp, error) {
+		if req.ID == 0 {
+			return typedUserResp{}, NewHTTPError(http.StatusNotFound, "用户不存在")
+		}
+		return typedUserResp{ID: req.ID, Name: req.Name, Notify: req.Notify}, nil
+	}
+	s := NewHTTPServer()
+	HandleTyped(s, http.MethodPut, "/users/:id", updateUser)
+	HandleTyped(s, http.MethodGet, "/ids", func(ctx *Context, req *struct {
+		IDs []int64 `query:"id"`
+	}) ([]int64, error) {
+		return req.IDs, nil
+	})
+	HandleTyped(s, http.MethodPost, "/sum", func(ctx *Context, req []int) (*wrapperspb.Int64Value, error) {
+		var sum int64
+		for _, v := range req {
+			sum += int64(v)
+		}
+		return wrapperspb.Int64(sum), nil
+	})
+	api := s.Group("/api", Enveloped(nil))
+	HandleTyped(api, http.MethodPut, "/users/:id", updateUser)
+	api.GET("/fail", Typed(func(ctx *Context, req struct{}) (any, error) {
+		return nil, NewBizError(10001, "余额不足")
+	}))
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		accept   string
+		body     string
+		wantCode int
+		wantType string
+		wantBody string
+	}{
+		{
+			name:     "json",
+			method:   http.MethodPut,
+			path:     "/users/123?notify=true",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusOK,
+			wantType: MIMEJSON,
+			wantBody: `{"id":123,"name":"Tom","notify":true}`,
+		},
+		{
+			name:     "xml",
+			method:   http.MethodPut,
+			path:     "/users/123",
+			accept:   "application/xml",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusOK,
+			wantType: MIMEXML,
+			wantBody: `<typedUserResp><id>123</id><name>Tom</name><notify>false</notify></typedUserResp>`,
+		},
+		{
+			name:     "validation error",
+			method:   http.MethodPut,
+			path:     "/users/123",
+			body:     `{}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantType: MIMEJSON,
+			wantBody: `{"msg":"参数校验失败","fields":{"name":"不能为空"}}`,
+		},
+		{
+			name:     "bind path error",
+			method:   http.MethodPut,
+			path:     "/users/abc",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "Bad Request",
+		},
+		{
+			name:     "handler error",
+			method:   http.MethodPut,
+			path:     "/users/0",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusNotFound,
+			wantBody: "用户不存在",
+		},
+		{
+			name:     "not acceptable",
+			method:   http.MethodPut,
+			path:     "/users/123",
+			accept:   "image/png",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusNotAcceptable,
+			wantBody: "Not Acceptable",
+		},
+		{
+			name:     "pointer req",
+			method:   http.MethodGet,
+			path:     "/ids?id=1&id=2",
+			wantCode: http.StatusOK,
+			wantType: MIMEJSON,
+			wantBody: `[1,2]`,
+		},
+		{
+			name:     "slice req",
+			method:   http.MethodPost,
+			path:     "/sum",
+			body:     `[1,2,3]`,
+			wantCode: http.StatusOK,
+			wantType: MIMEJSON,
+			wantBody: `{"value":6}`,
+		},
+		{
+			name:     "enveloped",
+			method:   http.MethodPut,
+			path:     "/api/users/123",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusOK,
+			wantType: MIMEJSON,
+			wantBody: `{"code":0,"msg":"ok","data":{"id":123,"name":"Tom","notify":false}}`,
+		},
+		{
+			name:     "enveloped error",
+			method:   http.MethodGet,
+			path:     "/api/fail",
+			wantCode: http.StatusBadRequest,
+			wantType: MIMEJSON,
+			wantBody: `{"code":10001,"msg":"余额不足","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", MIMEJSON)
+			}
+			req.Header.Set("Accept", tc.accept)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantType == "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+				return
+			}
+			assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), tc.wantType))
+			if tc.wantType == MIMEJSON {
+				assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+				return
+			}
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+
+	routes := s.Routes()
+	require.Len(t, routes, 5)
+	assert.Equal(t, RouteInfo{
+		Method: http.MethodPut,
+		Path:   "/api/users/:id",
+		Req:    reflect.TypeOf(typedUpdateUserReq{}),
+		Resp:   reflect.TypeOf(typedUserResp{}),
+	}, routes[1])
+	assert.Equal(t, RouteInfo{Method: http.MethodGet, Path: "/api/fail"}, routes[0])
+	assert.Equal(t, reflect.TypeOf([]int{}), routes[3].Req)
+}
+
+func TestTyped_Error(t *testing.T) {
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil)}
+	ctx.resp.reset(httptest.NewRecorder())
+	ctx.Resp = &ctx.resp
+	called := false
+	Typed(func(ctx *Context, req struct{}) (int, error) {
+		called = true
+		return 0, errors.New("db error")
+	})(ctx)
+	assert.True(t, called)
+	assert.Equal(t, http.StatusInternalServerError, ctx.RespStatusCode)
+}
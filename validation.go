@@ -0,0 +1,323 @@
+package web
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// tagValidate 校验规则的 tag，多个规则用逗号分隔，规则的参数跟在 = 后面，例如
+// Name string `validate:"required,min=1,max=64"`
+// Role string `validate:"oneof=admin user"`
+// omitempty 代表字段为零值的时候跳过其它规则
+const tagValidate = "validate"
+
+// ValidateFunc 校验规则
+// val 是字段的值，param 是规则的参数，例如 min=1 中的 1
+// 返回 false 代表校验不通过，返回 error 代表规则本身用错了，例如 min 用在了 bool 上
+type ValidateFunc func(val reflect.Value, param string) (bool, error)
+
+type rule struct {
+	fn ValidateFunc
+	// msg 校验失败时的提示，%s 会被替换为规则的参数
+	msg string
+}
+
+// Validator 根据 validate tag 校验结构体
+type Validator struct {
+	mutex sync.RWMutex
+	rules map[string]rule
+}
+
+// NewValidator 创建 Validator，内置了 required、min、max、len、email、oneof 规则
+func NewValidator() *Validator {
+	v := &Validator{
+		rules: make(map[string]rule, 8),
+	}
+	v.RegisterRule("required", validateRequired, "不能为空")
+	v.RegisterRule("min", validateMin, "不能小于 %s")
+	v.RegisterRule("max", validateMax, "不能大于 %s")
+	v.RegisterRule("len", validateLen, "长度必须是 %s")
+	v.RegisterRule("email", validateEmail, "不是合法的邮箱")
+	v.RegisterRule("oneof", validateOneOf, "必须是 [%s] 中的一个")
+	return v
+}
+
+// defaultValidator 没有设置 Validator 的时候使用
+var defaultValidator = NewValidator()
+
+// RegisterRule 注册校验规则，同名的规则会被覆盖
+// msg 是校验失败时返回给前端的提示，可以用 %s 引用规则的参数
+func (v *Validator) RegisterRule(name string, fn ValidateFunc, msg string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.rules[name] = rule{fn: fn, msg: msg}
+}
+
+// ServerWithValidator 设置绑定参数之后使用的 Validator
+func ServerWithValidator(v *Validator) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.validator = v
+	}
+}
+
+func (c *Context) validate(val any) error {
+	v := c.validator
+	if v == nil {
+		v = defaultValidator
+	}
+	return v.Validate(val)
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	// Field 字段名，优先使用 json tag 里面的名字，嵌套的字段用 . 连接
+	Field string
+	Rule  string
+	Param string
+	Msg   string
+}
+
+// ValidationErrors 一个结构体里面所有校验失败的字段
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	var sb strings.Builder
+	sb.WriteString("web: 参数校验失败")
+	for _, fe := range e {
+		sb.WriteString(fmt.Sprintf(", %s %s", fe.Field, fe.Msg))
+	}
+	return sb.String()
+}
+
+// fields 字段名到错误信息的映射
+func (e ValidationErrors) fields() map[string]string {
+	res := make(map[string]string, len(e))
+	for _, fe := range e {
+		res[fe.Field] = fe.Msg
+	}
+	return res
+}
+
+// Validate 校验结构体，val 可以是结构体或者结构体指针，其它类型直接返回 nil
+// 所有校验失败的字段都会通过 ValidationErrors 返回
+func (v *Validator) Validate(val any) error {
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := v.validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *Validator) validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	typ := rv.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		fd := typ.Field(i)
+		if !fd.IsExported() && !fd.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		name := prefix + fieldName(fd)
+		if tag := fd.Tag.Get(tagValidate); tag != "" && tag != "-" {
+			if err := v.validateField(fv, name, tag, errs); err != nil {
+				return err
+			}
+		}
+		nestedPrefix := name + "."
+		if fd.Anonymous {
+			// 匿名字段里面的字段和外层平铺在一起
+			nestedPrefix = prefix
+		}
+		if err := v.validateNested(fv, name, nestedPrefix, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested 嵌套的结构体以及结构体切片，也要校验
+// prefix 是嵌套结构体里面字段名的前缀
+func (v *Validator) validateNested(fv reflect.Value, name string, prefix string, errs *ValidationErrors) error {
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type() == timeType {
+			return nil
+		}
+		return v.validateStruct(fv, prefix, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			elemName := fmt.Sprintf("%s[%d]", name, i)
+			if err := v.validateNested(fv.Index(i), elemName, elemName+".", errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Validator) validateField(fv reflect.Value, name string, tag string, errs *ValidationErrors) error {
+	rules := strings.Split(tag, ",")
+	for _, r := range rules {
+		if r == "omitempty" {
+			if fv.IsZero() {
+				return nil
+			}
+			continue
+		}
+		ruleName, param, _ := strings.Cut(r, "=")
+		v.mutex.RLock()
+		rl, ok := v.rules[ruleName]
+		v.mutex.RUnlock()
+		if !ok {
+			return fmt.Errorf("web: 未知的校验规则 %s", ruleName)
+		}
+		pass, err := rl.fn(fv, param)
+		if err != nil {
+			return fmt.Errorf("web: 字段 %s 的校验规则 %s 有误: %w", name, ruleName, err)
+		}
+		if !pass {
+			msg := rl.msg
+			if strings.Contains(msg, "%s") {
+				msg = fmt.Sprintf(msg, param)
+			}
+			*errs = append(*errs, FieldError{Field: name, Rule: ruleName, Param: param, Msg: msg})
+			// 一个字段只报告第一个失败的规则
+			return nil
+		}
+	}
+	return nil
+}
+
+// fieldName 优先使用 json tag 里面的名字，这样前端看到的字段名和请求里的一致
+func fieldName(fd reflect.StructField) string {
+	if name, _, _ := strings.Cut(fd.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return fd.Name
+}
+
+func validateRequired(val reflect.Value, param string) (bool, error) {
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map:
+		return val.Len() > 0, nil
+	default:
+		return !val.IsZero(), nil
+	}
+}
+
+func validateMin(val reflect.Value, param string) (bool, error) {
+	res, err := compareSize(val, param)
+	return res >= 0, err
+}
+
+func validateMax(val reflect.Value, param string) (bool, error) {
+	res, err := compareSize(val, param)
+	return res <= 0, err
+}
+
+func validateLen(val reflect.Value, param string) (bool, error) {
+	res, err := compareSize(val, param)
+	return res == 0, err
+}
+
+// compareSize 比较 val 和 param 的大小，数字比较值，字符串比较字符数，切片和 map 比较长度
+// 返回值的含义和 strings.Compare 一样
+func compareSize(val reflect.Value, param string) (int, error) {
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return 0, nil
+		}
+		val = val.Elem()
+	}
+	var size float64
+	switch val.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(val.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		size = float64(val.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = val.Float()
+	default:
+		return 0, fmt.Errorf("不支持的类型 %s", val.Type())
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case size < limit:
+		return -1, nil
+	case size > limit:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+func validateEmail(val reflect.Value, param string) (bool, error) {
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return true, nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.String {
+		return false, fmt.Errorf("不支持的类型 %s", val.Type())
+	}
+	addr, err := mail.ParseAddress(val.String())
+	// ParseAddress 也接受 "Tom <tom@example.com>" 这种形式
+	return err == nil && addr.Address == val.String(), nil
+}
+
+func validateOneOf(val reflect.Value, param string) (bool, error) {
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return true, nil
+		}
+		val = val.Elem()
+	}
+	var s string
+	switch val.Kind() {
+	case reflect.String:
+		s = val.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(val.Uint(), 10)
+	default:
+		return false, fmt.Errorf("不支持的类型 %s", val.Type())
+	}
+	for _, opt := range strings.Fields(param) {
+		if s == opt {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,148 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateUser struct {
+	Name    string            `json:"name" validate:"required,min=2,max=8"`
+	Email   string            `json:"email" validate:"omitempty,email"`
+	Role    string            `json:"role" validate:"oneof=admin user"`
+	Age     int               `json:"age" validate:"min=18"`
+	Tags    []string          `json:"tags" validate:"max=2"`
+	Code    string            `json:"code" validate:"len=4"`
+	Addr    *validateAddress  `json:"addr"`
+	History []validateAddress `json:"history"`
+}
+
+func TestValidator_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		val     any
+		wantErr error
+	}{
+		{
+			name: "valid",
+			val: &validateUser{Name: "Tom", Email: "tom@example.com", Role: "admin",
+				Age: 18, Tags: []string{"a"}, Code: "abcd"},
+		},
+		{
+			name: "invalid",
+			val: validateUser{Name: "T", Email: "Tom <tom@example.com>", Role: "guest",
+				Age: 17, Tags: []string{"a", "b", "c"}, Code: "中文编码",
+				Addr: &validateAddress{}, History: []validateAddress{{City: "shanghai"}, {}}},
+			wantErr: ValidationErrors{
+				{Field: "name", Rule: "min", Param: "2", Msg: "不能小于 2"},
+				{Field: "email", Rule: "email", Msg: "不是合法的邮箱"},
+				{Field: "role", Rule: "oneof", Param: "admin user", Msg: "必须是 [admin user] 中的一个"},
+				{Field: "age", Rule: "min", Param: "18", Msg: "不能小于 18"},
+				{Field: "tags", Rule: "max", Param: "2", Msg: "不能大于 2"},
+				{Field: "addr.city", Rule: "required", Msg: "不能为空"},
+				{Field: "history[1].city", Rule: "required", Msg: "不能为空"},
+			},
+		},
+		{
+			name: "pointer",
+			val: struct {
+				Email   *string `json:"email" validate:"email"`
+				Backup  *string `json:"backup" validate:"email"`
+				Invalid *string `json:"invalid" validate:"email"`
+			}{Email: ptrOf("tom@example.com"), Invalid: ptrOf("abc")},
+			wantErr: ValidationErrors{
+				{Field: "invalid", Rule: "email", Msg: "不是合法的邮箱"},
+			},
+		},
+		{
+			name: "not struct",
+			val:  "abc",
+		},
+	}
+	v := NewValidator()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantErr, v.Validate(tc.val))
+		})
+	}
+}
+
+func TestValidator_RegisterRule(t *testing.T) {
+	v := NewValidator()
+	v.RegisterRule("prefix", func(val reflect.Value, param string) (bool, error) {
+		return strings.HasPrefix(val.String(), param), nil
+	}, "必须以 %s 开头")
+	var req struct {
+		Phone string `validate:"prefix=+86"`
+	}
+	req.Phone = "+1 123"
+	assert.Equal(t, ValidationErrors{
+		{Field: "Phone", Rule: "prefix", Param: "+86", Msg: "必须以 +86 开头"},
+	}, v.Validate(req))
+
+	var bad struct {
+		Admin bool `validate:"min=1"`
+	}
+	assert.EqualError(t, v.Validate(bad), "web: 字段 Admin 的校验规则 min 有误: 不支持的类型 bool")
+}
+
+func TestContext_BindValidate(t *testing.T) {
+	s := NewHTTPServer()
+	s.POST("/user", HandleE(func(ctx *Context) error {
+		var u validateUser
+		if err := ctx.Bind(&u); err != nil {
+			return err
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(u.Name)
+		return nil
+	}))
+
+	testCases := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "ok",
+			body:     `{"name":"Tom","role":"user","age":20,"code":"abcd"}`,
+			wantCode: http.StatusOK,
+			wantBody: "Tom",
+		},
+		{
+			name:     "validation",
+			body:     `{"name":"","role":"user","age":20,"code":"abcd","email":"abc"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `{"fields":{"email":"不是合法的邮箱","name":"不能为空"},"msg":"参数校验失败"}`,
+		},
+		{
+			name:     "bad json",
+			body:     `{"name":`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "Bad Request",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", MIMEJSON)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			require.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func ptrOf[T any](val T) *T {
+	return &val
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"sync"
+)
+
+// Key 带类型的 key，配合 Set 和 Get 使用
+// 比较的是指针，所以不同的 Key 即使 name 一样也不会冲突，name 只是为了方便调试
+type Key[T any] struct {
+	name string
+}
+
+// NewKey 一般定义为包变量，例如 var userKey = web.NewKey[*User]("user")
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string {
+	return "web.Key(" + k.name + ")"
+}
+
+// Value 从 context.Context 中读取 Set 设置的值，
+// 给只能拿到 ctx.Req.Context() 的下游代码使用
+func (k *Key[T]) Value(ctx context.Context) (T, bool) {
+	val, ok := ctx.Value(k).(T)
+	return val, ok
+}
+
+// Set 把 val 保存到 Values 里面，可以在多个 goroutine 中同时调用
+func (k *Key[T]) Set(v *Values, val T) {
+	v.store(k, val)
+}
+
+// Set 在 Context 上保存 val，可以在多个 goroutine 中同时调用，
+// ctx.Req.Context().Value(key) 也能读到
+func Set[T any](ctx *Context, key *Key[T], val T) {
+	key.Set(ctx.Values(), val)
+}
+
+// Get 读取 Set 保存的值，没有的时候返回 T 的零值和 false
+func Get[T any](ctx *Context, key *Key[T]) (T, bool) {
+	return key.Value(ctx.Values())
+}
+
+// Values 返回当前请求的 Values，它不会跟着 Context 复用，
+// 所以 handler 启动的 goroutine 在 handler 返回之后，应该使用它而不是 Context，例如
+//
+//	vals := ctx.Values()
+//	go func() {
+//		user, _ := userKey.Value(vals)
+//		userKey.Set(vals, user)
+//	}()
+func (c *Context) Values() *Values {
+	c.valuesMu.Lock()
+	defer c.valuesMu.Unlock()
+	if c.values == nil {
+		// 没有经过 HTTPServer 创建的 Context，例如测试里面手动创建的，
+		// HTTPServer 会在执行 handler 之前就创建好，不会在这里替换 ctx.Req
+		c.bindValues()
+	}
+	return c.values
+}
+
+// bindValues 创建 Values 并且替换 c.Req，使得 Set 的值会同步到 c.Req.Context() 上
+func (c *Context) bindValues() {
+	c.values = &Values{Context: c.Req.Context()}
+	c.Req = c.Req.WithContext(c.values)
+}
+
+// Values 一个请求里面通过 Set 保存的值，同时也是请求的 context.Context
+// 每个请求都是新创建的，不跟着 Context 复用，避免请求结束之后还持有它的 goroutine 读到下一个请求的值
+type Values struct {
+	context.Context
+	mu   sync.RWMutex
+	vals map[any]any
+}
+
+func (s *Values) store(key, val any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.vals == nil {
+		s.vals = make(map[any]any, 4)
+	}
+	s.vals[key] = val
+}
+
+func (s *Values) load(key any) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	val, ok := s.vals[key]
+	return val, ok
+}
+
+func (s *Values) Value(key any) any {
+	if val, ok := s.load(key); ok {
+		return val
+	}
+	return s.Context.Value(key)
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSetAndGet(t *testing.T) {
+	nameKey := NewKey[string]("name")
+	// 名字一样，但是是不同的 key
+	otherNameKey := NewKey[string]("name")
+	ageKey := NewKey[int]("age")
+
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil)}
+	_, ok := Get(ctx, nameKey)
+	assert.False(t, ok)
+
+	Set(ctx, nameKey, "Tom")
+	Set(ctx, ageKey, 18)
+	name, ok := Get(ctx, nameKey)
+	require.True(t, ok)
+	assert.Equal(t, "Tom", name)
+	_, ok = Get(ctx, otherNameKey)
+	assert.False(t, ok)
+
+	// 同步到了 ctx.Req.Context() 上
+	age, ok := ageKey.Value(ctx.Req.Context())
+	require.True(t, ok)
+	assert.Equal(t, 18, age)
+	// 派生出来的 context 也能读到
+	sub, cancel := context.WithCancel(ctx.Req.Context())
+	defer cancel()
+	name, ok = nameKey.Value(sub)
+	require.True(t, ok)
+	assert.Equal(t, "Tom", name)
+}
+
+func TestSet_Concurrent(t *testing.T) {
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil)}
+	keys := make([]*Key[int], 20)
+	for i := range keys {
+		keys[i] = NewKey[int](strconv.Itoa(i))
+	}
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key *Key[int]) {
+			defer wg.Done()
+			Set(ctx, key, i)
+			_, _ = Get(ctx, key)
+		}(i, key)
+	}
+	wg.Wait()
+	for i, key := range keys {
+		val, ok := Get(ctx, key)
+		require.True(t, ok)
+		assert.Equal(t, i, val)
+	}
+}
+
+func TestSetAndGet_Server(t *testing.T) {
+	userKey := NewKey[string]("user")
+	s := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if user, ok := Get(ctx, userKey); ok {
+				t.Fatalf("上一个请求的值 %s 没有被清空", user)
+			}
+			Set(ctx, userKey, ctx.Req.URL.Query().Get("user"))
+			next(ctx)
+		}
+	}))
+	s.GET("/", func(ctx *Context) {
+		// 模拟只能拿到 context.Context 的下游代码
+		user, _ := userKey.Value(ctx.Req.Context())
+		ctx.RespString(http.StatusOK, user)
+	})
+	for _, user := range []string{"Tom", "Jerry"} {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?user="+user, nil))
+		assert.Equal(t, user, recorder.Body.String())
+	}
+}
+
+// TestValues_Goroutine handler 启动的 goroutine 在第一次 Set 之前就开始读取 ctx.Req，
+// 并且在 handler 返回、Context 被复用之后，依旧通过 Values 读写当前请求的值
+func TestValues_Goroutine(t *testing.T) {
+	userKey := NewKey[string]("user")
+	type result struct {
+		before string
+		after  string
+	}
+	results := make(chan result, 2)
+	handlerDone := make(chan struct{}, 2)
+	s := NewHTTPServer()
+	s.GET("/", func(ctx *Context) {
+		vals := ctx.Values()
+		user := ctx.QueryValue("user").StringOrDefault("")
+		started := make(chan struct{})
+		read := make(chan struct{})
+		go func() {
+			close(started)
+			// 和下面的 Set 并发读取 ctx.Req
+			var before string
+			for i := 0; i < 100; i++ {
+				before, _ = userKey.Value(ctx.Req.Context())
+			}
+			close(read)
+			// 等到 handler 返回，Context 已经被下一个请求复用
+			<-handlerDone
+			userKey.Set(vals, user+"-async")
+			after, _ := userKey.Value(vals)
+			results <- result{before: before, after: after}
+		}()
+		<-started
+		Set(ctx, userKey, user)
+		<-read
+	})
+
+	for _, user := range []string{"Tom", "Jerry"} {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?user="+user, nil))
+		handlerDone <- struct{}{}
+		res := <-results
+		assert.Contains(t, []string{"", user}, res.before)
+		assert.Equal(t, user+"-async", res.after)
+	}
+}
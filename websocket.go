@@ -0,0 +1,638 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocketMessageType 消息的类型，对应 RFC 6455 里面的 opcode
+type WebSocketMessageType int
+
+const (
+	WebSocketText   WebSocketMessageType = 1
+	WebSocketBinary WebSocketMessageType = 2
+
+	wsContinuation = 0
+	wsClose        = 8
+	wsPing         = 9
+	wsPong         = 10
+)
+
+// RFC 6455 定义的关闭码
+const (
+	WebSocketCloseNormal          = 1000
+	WebSocketCloseGoingAway       = 1001
+	WebSocketCloseProtocolError   = 1002
+	WebSocketCloseUnsupportedData = 1003
+	WebSocketCloseNoStatus        = 1005
+	WebSocketCloseAbnormal        = 1006
+	WebSocketCloseInvalidPayload  = 1007
+	WebSocketClosePolicyViolation = 1008
+	WebSocketCloseMessageTooBig   = 1009
+	WebSocketCloseInternalError   = 1011
+)
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// defaultWebSocketReadLimit 单个消息默认的大小上限
+	defaultWebSocketReadLimit = 1 << 20
+	// maxControlPayload 控制帧的负载不能超过 125 字节
+	maxControlPayload = 125
+)
+
+var (
+	// ErrWebSocketHandshake 不是合法的 WebSocket 握手请求
+	ErrWebSocketHandshake = NewHTTPError(http.StatusBadRequest, "WebSocket 握手失败")
+	// ErrWebSocketOrigin Origin 没有通过 WebSocketWithCheckOrigin 的检查
+	ErrWebSocketOrigin = NewHTTPError(http.StatusForbidden, "WebSocket Origin 不允许")
+	// ErrWebSocketVersion 只支持 RFC 6455 的 13 版本
+	ErrWebSocketVersion = NewHTTPError(http.StatusUpgradeRequired, "只支持 WebSocket 13 版本")
+	// ErrWebSocketClosed 连接已经关闭了
+	ErrWebSocketClosed = errors.New("web: WebSocket 连接已经关闭")
+	// ErrWebSocketReadLimit 消息超过了 WebSocketWithReadLimit 设置的上限
+	ErrWebSocketReadLimit = errors.New("web: WebSocket 消息太大")
+)
+
+// WebSocketCloseError 对端发送了关闭帧，或者因为协议错误而关闭连接
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("web: WebSocket 连接关闭 %d %s", e.Code, e.Reason)
+}
+
+type webSocketConfig struct {
+	readLimit    int64
+	writeTimeout time.Duration
+	compression  bool
+	subprotocols []string
+	checkOrigin  func(req *http.Request) bool
+}
+
+type WebSocketOption func(cfg *webSocketConfig)
+
+// WebSocketWithReadLimit 单个消息的大小上限，包括解压之后的大小，默认是 1MB
+// 超过上限的时候会以 1009 关闭连接
+// 不支持关闭上限，n 小于等于 0 的时候会 panic，否则客户端声明一个超大的帧就能耗尽内存
+func WebSocketWithReadLimit(n int64) WebSocketOption {
+	if n <= 0 {
+		panic(fmt.Sprintf("web: WebSocket 消息的大小上限必须大于 0 [%d]", n))
+	}
+	return func(cfg *webSocketConfig) {
+		cfg.readLimit = n
+	}
+}
+
+// WebSocketWithWriteTimeout 每次写入的超时时间，默认不超时
+func WebSocketWithWriteTimeout(timeout time.Duration) WebSocketOption {
+	return func(cfg *webSocketConfig) {
+		cfg.writeTimeout = timeout
+	}
+}
+
+// WebSocketWithCompression 客户端支持的时候，使用 permessage-deflate 压缩消息
+// 为了简单，双方都不保留压缩的上下文，也就是每个消息单独压缩
+func WebSocketWithCompression() WebSocketOption {
+	return func(cfg *webSocketConfig) {
+		cfg.compression = true
+	}
+}
+
+// WebSocketWithSubprotocols 服务端支持的子协议，按照优先级排列
+func WebSocketWithSubprotocols(protocols ...string) WebSocketOption {
+	return func(cfg *webSocketConfig) {
+		cfg.subprotocols = protocols
+	}
+}
+
+// WebSocketWithCheckOrigin 检查 Origin，防止跨站 WebSocket 劫持
+// 默认只允许没有 Origin，或者 Origin 的 host 和请求的 host 一样
+func WebSocketWithCheckOrigin(fn func(req *http.Request) bool) WebSocketOption {
+	return func(cfg *webSocketConfig) {
+		cfg.checkOrigin = fn
+	}
+}
+
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	_, host, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(host, req.Host)
+}
+
+// WebSocket 把当前连接升级为 WebSocket，握手失败的时候返回 HTTPError，连接依旧可以正常响应
+// 升级之后连接就不归 HTTPServer 管了，用完之后需要调用 Close，例如
+//
+//	server.GET("/ws", web.HandleE(func(ctx *web.Context) error {
+//		conn, err := ctx.WebSocket()
+//		if err != nil {
+//			return err
+//		}
+//		defer conn.Close()
+//		for {
+//			typ, data, err := conn.ReadMessage()
+//			if err != nil {
+//				return nil
+//			}
+//			if err = conn.WriteMessage(typ, data); err != nil {
+//				return nil
+//			}
+//		}
+//	}))
+//
+// 在此之前 middleware 设置的响应头，例如 Set-Cookie，会跟着握手响应一起返回
+func (c *Context) WebSocket(opts ...WebSocketOption) (*WebSocketConn, error) {
+	cfg := &webSocketConfig{
+		readLimit:   defaultWebSocketReadLimit,
+		checkOrigin: sameOrigin,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	req := c.Req
+	if req.Method != http.MethodGet ||
+		!headerContainsToken(req.Header, "Connection", "upgrade") ||
+		!headerContainsToken(req.Header, "Upgrade", "websocket") {
+		return nil, ErrWebSocketHandshake.Wrap(errors.New("web: 缺少 Upgrade: websocket 请求头"))
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Resp.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, ErrWebSocketVersion
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, ErrWebSocketHandshake.Wrap(errors.New("web: 非法的 Sec-WebSocket-Key"))
+	}
+	if !cfg.checkOrigin(req) {
+		return nil, ErrWebSocketOrigin
+	}
+	json, err := c.codec(MIMEJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	header := c.Resp.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", webSocketAccept(key))
+	protocol := selectSubprotocol(cfg.subprotocols, headerTokens(req.Header, "Sec-WebSocket-Protocol"))
+	if protocol != "" {
+		header.Set("Sec-WebSocket-Protocol", protocol)
+	}
+	compress := cfg.compression && acceptDeflate(req.Header)
+	if compress {
+		header.Set("Sec-WebSocket-Extensions", "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	}
+
+	netConn, brw, err := http.NewResponseController(c.Resp).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// http.Server 设置的超时对 WebSocket 来说没有意义
+	_ = netConn.SetDeadline(time.Time{})
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	_ = header.Write(&buf)
+	buf.WriteString("\r\n")
+	if cfg.writeTimeout > 0 {
+		_ = netConn.SetWriteDeadline(time.Now().Add(cfg.writeTimeout))
+	}
+	if _, err = netConn.Write(buf.Bytes()); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	return &WebSocketConn{
+		conn:         netConn,
+		br:           brw.Reader,
+		readLimit:    cfg.readLimit,
+		writeTimeout: cfg.writeTimeout,
+		compress:     compress,
+		subprotocol:  protocol,
+		json:         json,
+	}, nil
+}
+
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerTokens 逗号分隔的请求头，例如 Connection: keep-alive, Upgrade
+func headerTokens(header http.Header, key string) []string {
+	var res []string
+	for _, val := range header.Values(key) {
+		for _, token := range strings.Split(val, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				res = append(res, token)
+			}
+		}
+	}
+	return res
+}
+
+func headerContainsToken(header http.Header, key string, token string) bool {
+	for _, t := range headerTokens(header, key) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func selectSubprotocol(supported []string, offered []string) string {
+	for _, s := range supported {
+		for _, o := range offered {
+			if s == o {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// acceptDeflate 客户端提供的 permessage-deflate 参数里面，
+// 只有不保留上下文以及 client_max_window_bits 是我们能够接受的
+func acceptDeflate(header http.Header) bool {
+	for _, ext := range headerTokens(header, "Sec-WebSocket-Extensions") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		ok := true
+		for _, param := range params[1:] {
+			name, _, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch name {
+			case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+			default:
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketConn 升级之后的 WebSocket 连接
+// 同一时间只能有一个 goroutine 读，但是可以有多个 goroutine 同时写
+type WebSocketConn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	readLimit    int64
+	writeTimeout time.Duration
+	compress     bool
+	subprotocol  string
+	json         Codec
+
+	pongHandler func(data string)
+
+	writeMu sync.Mutex
+	// closeSent 已经发送了关闭帧，之后不能再发送任何帧
+	closeSent bool
+}
+
+// Subprotocol 协商出来的子协议，没有的时候是空字符串
+func (w *WebSocketConn) Subprotocol() string {
+	return w.subprotocol
+}
+
+func (w *WebSocketConn) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
+
+// SetReadDeadline 一般配合 ping 和 SetPongHandler 使用，收到 pong 的时候延长读超时，用于检测死连接
+func (w *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return w.conn.SetReadDeadline(t)
+}
+
+// SetPongHandler 收到 pong 的时候调用，在 ReadMessage 的 goroutine 中执行
+func (w *WebSocketConn) SetPongHandler(fn func(data string)) {
+	w.pongHandler = fn
+}
+
+// ReadMessage 读取一个完整的消息，分片的消息会被拼接起来
+// ping、pong、关闭帧在内部处理：收到 ping 自动回复 pong，
+// 收到关闭帧的时候回复关闭帧并且返回 *WebSocketCloseError
+func (w *WebSocketConn) ReadMessage() (WebSocketMessageType, []byte, error) {
+	var (
+		typ        WebSocketMessageType
+		compressed bool
+		msg        []byte
+	)
+	for {
+		f, err := w.readFrame(int64(len(msg)))
+		if err != nil {
+			return 0, nil, w.failRead(err)
+		}
+		switch f.opcode {
+		case wsPing:
+			if err = w.writeFrame(wsPong, true, false, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			if w.pongHandler != nil {
+				w.pongHandler(string(f.payload))
+			}
+			continue
+		case wsClose:
+			return 0, nil, w.handleClose(f.payload)
+		case wsContinuation:
+			if typ == 0 {
+				return 0, nil, w.failRead(protocolError("没有开始的分片消息"))
+			}
+			if f.rsv1 {
+				return 0, nil, w.failRead(protocolError("只有第一个分片可以设置 RSV1"))
+			}
+		case int(WebSocketText), int(WebSocketBinary):
+			if typ != 0 {
+				return 0, nil, w.failRead(protocolError("上一个分片消息还没有结束"))
+			}
+			if f.rsv1 && !w.compress {
+				return 0, nil, w.failRead(protocolError("没有协商压缩却设置了 RSV1"))
+			}
+			typ, compressed = WebSocketMessageType(f.opcode), f.rsv1
+		default:
+			return 0, nil, w.failRead(protocolError(fmt.Sprintf("未知的 opcode %d", f.opcode)))
+		}
+		msg = append(msg, f.payload...)
+		if !f.fin {
+			continue
+		}
+		if compressed {
+			if msg, err = w.decompress(msg); err != nil {
+				return 0, nil, w.failRead(err)
+			}
+		}
+		if typ == WebSocketText && !utf8.Valid(msg) {
+			return 0, nil, w.failRead(&WebSocketCloseError{Code: WebSocketCloseInvalidPayload, Reason: "文本消息不是合法的 UTF-8"})
+		}
+		return typ, msg, nil
+	}
+}
+
+// ReadJSON 读取一个消息并且解析为 JSON
+func (w *WebSocketConn) ReadJSON(val any) error {
+	_, data, err := w.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return w.json.Decode(bytes.NewReader(data), val)
+}
+
+// WriteMessage 发送一个消息，协商了压缩的时候会压缩
+func (w *WebSocketConn) WriteMessage(typ WebSocketMessageType, data []byte) error {
+	if typ != WebSocketText && typ != WebSocketBinary {
+		return fmt.Errorf("web: 非法的消息类型 %d", typ)
+	}
+	if !w.compress {
+		return w.writeFrame(int(typ), true, false, data)
+	}
+	compressed, err := compressMessage(data)
+	if err != nil {
+		return err
+	}
+	return w.writeFrame(int(typ), true, true, compressed)
+}
+
+// WriteJSON 把 val 编码为 JSON，作为文本消息发送
+func (w *WebSocketConn) WriteJSON(val any) error {
+	data, err := w.json.Encode(val)
+	if err != nil {
+		return err
+	}
+	return w.WriteMessage(WebSocketText, data)
+}
+
+// WritePing 发送 ping，data 不能超过 125 字节
+func (w *WebSocketConn) WritePing(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("web: ping 的数据不能超过 125 字节")
+	}
+	return w.writeFrame(wsPing, true, false, data)
+}
+
+// Close 以 1000 关闭连接
+func (w *WebSocketConn) Close() error {
+	return w.CloseWithCode(WebSocketCloseNormal, "")
+}
+
+// CloseWithCode 发送关闭帧并且关闭底层的连接
+func (w *WebSocketConn) CloseWithCode(code int, reason string) error {
+	_ = w.writeClose(code, reason)
+	return w.conn.Close()
+}
+
+func (w *WebSocketConn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	return w.writeFrame(wsClose, true, false, payload)
+}
+
+// handleClose 回复关闭帧并且关闭连接
+func (w *WebSocketConn) handleClose(payload []byte) error {
+	ce := &WebSocketCloseError{Code: WebSocketCloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		ce = &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "非法的关闭帧"}
+	case len(payload) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Reason = string(payload[2:])
+		if !validCloseCode(ce.Code) || !utf8.Valid(payload[2:]) {
+			ce = &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "非法的关闭帧"}
+		}
+	}
+	replyCode := ce.Code
+	if replyCode == WebSocketCloseNoStatus {
+		replyCode = WebSocketCloseNormal
+	}
+	_ = w.writeClose(replyCode, "")
+	_ = w.conn.Close()
+	return ce
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		// 1004、1005、1006 不允许出现在关闭帧里面
+		return code != 1004 && code != WebSocketCloseNoStatus && code != WebSocketCloseAbnormal
+	default:
+		return false
+	}
+}
+
+func protocolError(reason string) error {
+	return &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: reason}
+}
+
+// failRead 协议错误以及消息太大的时候，发送对应的关闭帧并且关闭连接
+func (w *WebSocketConn) failRead(err error) error {
+	var ce *WebSocketCloseError
+	switch {
+	case errors.As(err, &ce):
+		_ = w.CloseWithCode(ce.Code, "")
+	case errors.Is(err, ErrWebSocketReadLimit):
+		_ = w.CloseWithCode(WebSocketCloseMessageTooBig, "")
+	}
+	return err
+}
+
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+// readFrame read 是当前消息已经读取的长度，用于检查消息大小的上限
+func (w *WebSocketConn) readFrame(read int64) (wsFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(w.br, head[:]); err != nil {
+		return wsFrame{}, err
+	}
+	f := wsFrame{
+		fin:    head[0]&0x80 != 0,
+		rsv1:   head[0]&0x40 != 0,
+		opcode: int(head[0] & 0x0f),
+	}
+	if head[0]&0x30 != 0 {
+		return f, protocolError("RSV2 和 RSV3 必须是 0")
+	}
+	// 客户端发送的帧必须带掩码
+	if head[1]&0x80 == 0 {
+		return f, protocolError("客户端的帧没有掩码")
+	}
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return f, err
+		}
+		l := binary.BigEndian.Uint64(ext[:])
+		if l > 1<<63-1 {
+			return f, protocolError("非法的帧长度")
+		}
+		length = int64(l)
+	}
+	isControl := f.opcode >= wsClose
+	if isControl && (length > maxControlPayload || !f.fin || f.rsv1) {
+		return f, protocolError("非法的控制帧")
+	}
+	if !isControl && read+length > w.readLimit {
+		return f, ErrWebSocketReadLimit
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(w.br, mask[:]); err != nil {
+		return f, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(w.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+func (w *WebSocketConn) writeFrame(opcode int, fin bool, rsv1 bool, payload []byte) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	if w.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == wsClose {
+		w.closeSent = true
+	}
+
+	header := make([]byte, 2, 10)
+	if fin {
+		header[0] |= 0x80
+	}
+	if rsv1 {
+		header[0] |= 0x40
+	}
+	header[0] |= byte(opcode)
+	// 服务端发送的帧不带掩码
+	switch l := len(payload); {
+	case l < 126:
+		header[1] = byte(l)
+	case l <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(l))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(l))
+	}
+	if w.writeTimeout > 0 {
+		_ = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+	bufs := net.Buffers{header, payload}
+	_, err := bufs.WriteTo(w.conn)
+	return err
+}
+
+// deflateTail 压缩之后去掉的结尾，解压的时候需要补回来
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+func compressMessage(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+func (w *WebSocketConn) decompress(data []byte) ([]byte, error) {
+	// 最后补上一个空的 final block，否则 flate 会返回 unexpected EOF
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(data),
+		bytes.NewReader(deflateTail), bytes.NewReader([]byte{0x01, 0x00, 0x00, 0xff, 0xff})))
+	defer fr.Close()
+	res, err := io.ReadAll(io.LimitReader(fr, w.readLimit+1))
+	if err != nil {
+		return nil, &WebSocketCloseError{Code: WebSocketCloseInvalidPayload, Reason: "解压失败"}
+	}
+	if int64(len(res)) > w.readLimit {
+		return nil, ErrWebSocketReadLimit
+	}
+	return res, nil
+}
@@ -0,0 +1,365 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestClient 测试用的极简客户端，直接读写帧
+type wsTestClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dialWebSocket(t *testing.T, url string, header http.Header) *wsTestClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, err := http.NewRequest(http.MethodGet, url+"/ws", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	require.NoError(t, req.Write(conn))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	return &wsTestClient{conn: conn, br: br, resp: resp}
+}
+
+func (c *wsTestClient) writeFrame(t *testing.T, fin bool, rsv1 bool, opcode int, payload []byte, masked bool) {
+	var b0 byte
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	b0 |= byte(opcode)
+	frame := []byte{b0}
+	var b1 byte
+	if masked {
+		b1 = 0x80
+	}
+	switch l := len(payload); {
+	case l < 126:
+		frame = append(frame, b1|byte(l))
+	case l <= 0xffff:
+		frame = binary.BigEndian.AppendUint16(append(frame, b1|126), uint16(l))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, b1|127), uint64(l))
+	}
+	data := append([]byte{}, payload...)
+	if masked {
+		mask := []byte{1, 2, 3, 4}
+		frame = append(frame, mask...)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	_, err := c.conn.Write(append(frame, data...))
+	require.NoError(t, err)
+}
+
+func (c *wsTestClient) readFrame(t *testing.T) (wsFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return wsFrame{}, err
+	}
+	require.Zero(t, head[1]&0x80, "服务端的帧不能有掩码")
+	length := int(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err := io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err := io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err := io.ReadFull(c.br, payload)
+	require.NoError(t, err)
+	return wsFrame{fin: head[0]&0x80 != 0, rsv1: head[0]&0x40 != 0, opcode: int(head[0] & 0x0f), payload: payload}, nil
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+// newWebSocketServer handler 返回的 error 会发送到 errs 里面
+func newWebSocketServer(t *testing.T, errs chan error, opts ...WebSocketOption) *httptest.Server {
+	s := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		// 模拟登录校验
+		return func(ctx *Context) {
+			if ctx.Req.Header.Get("Authorization") != "token" {
+				ctx.RespStatusCode = http.StatusUnauthorized
+				return
+			}
+			ctx.SetCookie(&http.Cookie{Name: "sess", Value: "123"})
+			next(ctx)
+		}
+	}))
+	s.GET("/ws", HandleE(func(ctx *Context) error {
+		conn, err := ctx.WebSocket(opts...)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return nil
+			}
+			if err = conn.WriteMessage(typ, data); err != nil {
+				errs <- err
+				return nil
+			}
+		}
+	}))
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server
+}
+
+var authHeader = http.Header{"Authorization": {"token"}}
+
+func TestContext_WebSocket(t *testing.T) {
+	errs := make(chan error, 1)
+	server := newWebSocketServer(t, errs, WebSocketWithSubprotocols("v2", "v1"))
+	client := dialWebSocket(t, server.URL, http.Header{
+		"Authorization":          {"token"},
+		"Sec-Websocket-Protocol": {"v1, v2"},
+	})
+	require.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	// RFC 6455 里面的例子
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", client.resp.Header.Get("Sec-WebSocket-Accept"))
+	assert.Equal(t, "v2", client.resp.Header.Get("Sec-WebSocket-Protocol"))
+	assert.Empty(t, client.resp.Header.Get("Sec-WebSocket-Extensions"))
+	// middleware 设置的响应头跟着握手响应一起返回
+	assert.Equal(t, "sess=123", client.resp.Header.Get("Set-Cookie"))
+
+	// 普通消息
+	client.writeFrame(t, true, false, int(WebSocketText), []byte("hello"), true)
+	f, err := client.readFrame(t)
+	require.NoError(t, err)
+	assert.Equal(t, wsFrame{fin: true, opcode: int(WebSocketText), payload: []byte("hello")}, f)
+
+	// 分片消息，中间夹着 ping
+	client.writeFrame(t, false, false, int(WebSocketBinary), []byte("hel"), true)
+	client.writeFrame(t, true, false, wsPing, []byte("are you there"), true)
+	client.writeFrame(t, true, false, wsContinuation, []byte("lo"), true)
+	f, err = client.readFrame(t)
+	require.NoError(t, err)
+	assert.Equal(t, wsFrame{fin: true, opcode: wsPong, payload: []byte("are you there")}, f)
+	f, err = client.readFrame(t)
+	require.NoError(t, err)
+	assert.Equal(t, wsFrame{fin: true, opcode: int(WebSocketBinary), payload: []byte("hello")}, f)
+
+	// 大一点的消息，长度需要用 2 个字节表示
+	large := bytes.Repeat([]byte("a"), 1000)
+	client.writeFrame(t, true, false, int(WebSocketBinary), large, true)
+	f, err = client.readFrame(t)
+	require.NoError(t, err)
+	assert.Equal(t, large, f.payload)
+
+	// 关闭握手
+	client.writeFrame(t, true, false, wsClose, closePayload(WebSocketCloseGoingAway, "bye"), true)
+	f, err = client.readFrame(t)
+	require.NoError(t, err)
+	assert.Equal(t, wsClose, f.opcode)
+	assert.Equal(t, closePayload(WebSocketCloseGoingAway, ""), f.payload)
+	err = <-errs
+	var ce *WebSocketCloseError
+	require.True(t, errors.As(err, &ce))
+	assert.Equal(t, &WebSocketCloseError{Code: WebSocketCloseGoingAway, Reason: "bye"}, ce)
+}
+
+func TestContext_WebSocketProtocolError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []WebSocketOption
+		send     func(t *testing.T, client *wsTestClient)
+		wantCode int
+	}{
+		{
+			name: "unmasked",
+			send: func(t *testing.T, client *wsTestClient) {
+				client.writeFrame(t, true, false, int(WebSocketText), []byte("hello"), false)
+			},
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name: "read limit",
+			opts: []WebSocketOption{WebSocketWithReadLimit(4)},
+			send: func(t *testing.T, client *wsTestClient) {
+				client.writeFrame(t, false, false, int(WebSocketText), []byte("hel"), true)
+				client.writeFrame(t, true, false, wsContinuation, []byte("lo"), true)
+			},
+			wantCode: WebSocketCloseMessageTooBig,
+		},
+		{
+			// 只发送帧头，声明一个超大的长度，不能按照这个长度分配内存
+			name: "huge frame",
+			send: func(t *testing.T, client *wsTestClient) {
+				head := binary.BigEndian.AppendUint64([]byte{0x80 | byte(WebSocketBinary), 0x80 | 127}, 1<<62)
+				_, err := client.conn.Write(append(head, 1, 2, 3, 4))
+				require.NoError(t, err)
+			},
+			wantCode: WebSocketCloseMessageTooBig,
+		},
+		{
+			name: "invalid utf8",
+			send: func(t *testing.T, client *wsTestClient) {
+				client.writeFrame(t, true, false, int(WebSocketText), []byte{0xff, 0xfe}, true)
+			},
+			wantCode: WebSocketCloseInvalidPayload,
+		},
+		{
+			name: "unexpected continuation",
+			send: func(t *testing.T, client *wsTestClient) {
+				client.writeFrame(t, true, false, wsContinuation, []byte("lo"), true)
+			},
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name: "fragmented control frame",
+			send: func(t *testing.T, client *wsTestClient) {
+				client.writeFrame(t, false, false, wsPing, []byte("ping"), true)
+			},
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name: "compressed without negotiation",
+			send: func(t *testing.T, client *wsTestClient) {
+				client.writeFrame(t, true, true, int(WebSocketText), []byte("hello"), true)
+			},
+			wantCode: WebSocketCloseProtocolError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := make(chan error, 1)
+			server := newWebSocketServer(t, errs, tc.opts...)
+			client := dialWebSocket(t, server.URL, authHeader)
+			require.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+			tc.send(t, client)
+			f, err := client.readFrame(t)
+			require.NoError(t, err)
+			assert.Equal(t, wsClose, f.opcode)
+			assert.Equal(t, tc.wantCode, int(binary.BigEndian.Uint16(f.payload)))
+			assert.Error(t, <-errs)
+			// 服务端已经关闭了连接
+			_, err = client.br.ReadByte()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWebSocketWithReadLimit(t *testing.T) {
+	assert.Panics(t, func() {
+		WebSocketWithReadLimit(0)
+	})
+	assert.Panics(t, func() {
+		WebSocketWithReadLimit(-1)
+	})
+}
+
+func TestContext_WebSocketCompression(t *testing.T) {
+	errs := make(chan error, 1)
+	server := newWebSocketServer(t, errs, WebSocketWithCompression())
+	client := dialWebSocket(t, server.URL, http.Header{
+		"Authorization":            {"token"},
+		"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"},
+	})
+	require.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	assert.Equal(t, "permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+		client.resp.Header.Get("Sec-WebSocket-Extensions"))
+
+	msg := strings.Repeat("hello websocket ", 100)
+	compressed, err := compressMessage([]byte(msg))
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(msg))
+	// 压缩的消息也可以分片，只有第一个分片设置 RSV1
+	client.writeFrame(t, false, true, int(WebSocketText), compressed[:10], true)
+	client.writeFrame(t, true, false, wsContinuation, compressed[10:], true)
+
+	f, err := client.readFrame(t)
+	require.NoError(t, err)
+	assert.True(t, f.rsv1)
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(f.payload), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff})))
+	data := make([]byte, len(msg))
+	_, err = io.ReadFull(fr, data)
+	require.NoError(t, err)
+	assert.Equal(t, msg, string(data))
+}
+
+func TestContext_WebSocketHandshake(t *testing.T) {
+	server := newWebSocketServer(t, make(chan error, 1))
+	testCases := []struct {
+		name       string
+		header     http.Header
+		wantCode   int
+		wantHeader http.Header
+	}{
+		{
+			// 被登录校验的 middleware 拦截了
+			name:     "unauthorized",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "not upgrade",
+			header:   http.Header{"Authorization": {"token"}, "Upgrade": {"h2c"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:       "version",
+			header:     http.Header{"Authorization": {"token"}, "Sec-Websocket-Version": {"8"}},
+			wantCode:   http.StatusUpgradeRequired,
+			wantHeader: http.Header{"Sec-Websocket-Version": {"13"}},
+		},
+		{
+			name:     "bad key",
+			header:   http.Header{"Authorization": {"token"}, "Sec-Websocket-Key": {"abc"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "cross origin",
+			header:   http.Header{"Authorization": {"token"}, "Origin": {"http://evil.com"}},
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := dialWebSocket(t, server.URL, tc.header)
+			assert.Equal(t, tc.wantCode, client.resp.StatusCode)
+			for k := range tc.wantHeader {
+				assert.Equal(t, tc.wantHeader.Get(k), client.resp.Header.Get(k))
+			}
+		})
+	}
+}